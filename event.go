@@ -144,6 +144,20 @@ func (e *Event) Close() {
 	// }
 }
 
+// Reopen the event if it is closed.
+func (e *Event) Reset() {
+	// e.lck.Lock()
+	if e.lck.TryLock(0) != nil {
+		return
+	}
+
+	if e.chanBroadcast == nil {
+		e.chanBroadcast = make(chan byte, 1)
+	}
+
+	e.lck.Unlock()
+}
+
 func (e *Event) IsClose() bool {
 	return e.GetChan() == nil
 }
@@ -0,0 +1,214 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	LOG_CALLER_PATH_FULL     = "full"
+	LOG_CALLER_PATH_SHORT    = "short"    // the last directory and the file name
+	LOG_CALLER_PATH_RELATIVE = "relative" // relative to the root directory
+
+	LOG_CALLER_BASE_SKIP = 5
+	LOG_ERROR_STACK_SIZE = 64 * 1024
+)
+
+//========================
+//    global method
+//========================
+
+// Set the count of extra stack frames to skip, for the libraries which wrap the logger.
+// @param skip, the count of frames between the user code and the Logger methods.
+func SetCallerSkip(skip int) {
+	loggerInst.updateCallerConf(func(c *logCallerConf) {
+		c.skip = skip
+	})
+}
+
+// Set the path mode of the caller.
+// @param mode, LOG_CALLER_PATH_XXX.
+// @param root, the root directory for LOG_CALLER_PATH_RELATIVE, empty mean the working directory.
+func SetCallerPathMode(mode string, root string) {
+	loggerInst.updateCallerConf(func(c *logCallerConf) {
+		c.setPathMode(mode, root)
+	})
+}
+
+// Show the function name with the caller.
+// @param bShowFunc, true mean show.
+func SetShowFunc(bShowFunc bool) {
+	loggerInst.updateCallerConf(func(c *logCallerConf) {
+		c.bShowFunc = bShowFunc
+	})
+}
+
+// Attach the stack trace of the current goroutine to the ERROR logs.
+// @param bErrorStack, true mean attach.
+func SetErrorStack(bErrorStack bool) {
+	loggerInst.updateCallerConf(func(c *logCallerConf) {
+		c.bErrorStack = bErrorStack
+	})
+}
+
+//========================
+//     logCallerConf
+//========================
+type logCallerInfo struct {
+	caller   string
+	funcName string
+}
+
+type logCallerConf struct {
+	skip        int
+	pathMode    string
+	root        string
+	bShowFunc   bool
+	bErrorStack bool
+	cache       *sync.Map // pc -> *logCallerInfo
+}
+
+func newLogCallerConf() *logCallerConf {
+	return &logCallerConf{
+		skip:        0,
+		pathMode:    LOG_CALLER_PATH_FULL,
+		root:        "",
+		bShowFunc:   false,
+		bErrorStack: false,
+		cache:       &sync.Map{},
+	}
+}
+
+func (c *logCallerConf) clone() *logCallerConf {
+	return &logCallerConf{
+		skip:        c.skip,
+		pathMode:    c.pathMode,
+		root:        c.root,
+		bShowFunc:   c.bShowFunc,
+		bErrorStack: c.bErrorStack,
+		cache:       &sync.Map{},
+	}
+}
+
+func (c *logCallerConf) setPathMode(mode string, root string) {
+	if len(mode) == 0 {
+		mode = LOG_CALLER_PATH_FULL
+	}
+
+	if mode == LOG_CALLER_PATH_RELATIVE && len(root) == 0 {
+		root, _ = os.Getwd()
+	}
+
+	root = filepath.ToSlash(root)
+	if len(root) > 0 && !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+
+	c.pathMode = mode
+	c.root = root
+}
+
+// Get the caller of the program counter.
+// @param pc, the program counter.
+// @return *logCallerInfo, the caller.
+func (c *logCallerConf) getCaller(pc uintptr) *logCallerInfo {
+	v, ok := c.cache.Load(pc)
+	if ok {
+		return v.(*logCallerInfo)
+	}
+
+	frames := runtime.CallersFrames([]uintptr{pc})
+	frame, _ := frames.Next()
+	info := &logCallerInfo{
+		caller:   c.formatPath(frame.File) + ":" + strconv.Itoa(frame.Line),
+		funcName: c.formatFunc(frame.Function),
+	}
+
+	c.cache.Store(pc, info)
+	return info
+}
+
+func (c *logCallerConf) formatPath(file string) string {
+	switch c.pathMode {
+	case LOG_CALLER_PATH_SHORT:
+		idx := strings.LastIndexByte(file, '/')
+		if idx > 0 {
+			idx = strings.LastIndexByte(file[:idx], '/')
+		}
+
+		return file[idx+1:]
+
+	case LOG_CALLER_PATH_RELATIVE:
+		if len(c.root) > 0 && strings.HasPrefix(file, c.root) {
+			return file[len(c.root):]
+		}
+
+		return file
+
+	default:
+		return file
+	}
+}
+
+func (c *logCallerConf) formatFunc(funcName string) string {
+	if c.pathMode == LOG_CALLER_PATH_FULL {
+		return funcName
+	}
+
+	// github.com/yxlib/yx.(*logger).printLog -> yx.(*logger).printLog
+	idx := strings.LastIndexByte(funcName, '/')
+	return funcName[idx+1:]
+}
+
+//========================
+//     logger caller
+//========================
+func (l *logger) getCallerConf() *logCallerConf {
+	return l.callerConf.Load().(*logCallerConf)
+}
+
+func (l *logger) updateCallerConf(update func(c *logCallerConf)) {
+	if l.lckCallerConf.TryLock(0) != nil {
+		return
+	}
+
+	defer l.lckCallerConf.Unlock()
+
+	c := l.getCallerConf().clone()
+	update(c)
+	l.callerConf.Store(c)
+}
+
+func (l *logger) configCaller(cfg *LogConf) {
+	l.updateCallerConf(func(c *logCallerConf) {
+		c.skip = cfg.CallerSkip
+		c.setPathMode(cfg.CallerPathMode, cfg.CallerRoot)
+		c.bShowFunc = cfg.IsShowFunc
+		c.bErrorStack = cfg.IsErrorStack
+	})
+}
+
+// Get the program counter of the user code.
+// @param c, the caller config.
+// @param extraSkip, the count of extra frames to skip, eg: the frames of the adapters.
+// @return uintptr, the program counter.
+func (l *logger) getCallerPc(c *logCallerConf, extraSkip int) uintptr {
+	pcs := [1]uintptr{}
+	runtime.Callers(LOG_CALLER_BASE_SKIP+c.skip+extraSkip, pcs[:])
+	return pcs[0]
+}
+
+// Get the stack trace of the current goroutine.
+// @return string, the stack trace.
+func getLogStack() string {
+	buf := make([]byte, LOG_ERROR_STACK_SIZE)
+	return string(buf[:runtime.Stack(buf, false)])
+}
@@ -0,0 +1,229 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const LOG_SINK_NAME_CAPTURE = "capture"
+
+//========================
+//    global method
+//========================
+
+// Capture the logs of the global logger in memory, for unit tests.
+// The sinks are swapped out and the level is set to LOG_LV_TRACE until restore,
+// the logger is started if not running.
+// @return *LogCapture, the capture.
+// @return func(), the function to restore the sinks and the level.
+func CaptureLogs() (*LogCapture, func()) {
+	return loggerInst.captureLogs()
+}
+
+// Create a running independent logger which only write to a capture, for the parallel tests.
+// @param tag, the tag of the logger.
+// @return *IndependentLogger, the logger, call StopLogger when done.
+// @return *LogCapture, the capture.
+func NewCaptureLogger(tag string) (*IndependentLogger, *LogCapture) {
+	l := NewIndependentLogger(tag)
+	c := newLogCapture(l.loggerImpl)
+	l.loggerImpl.SetLevel(LOG_LV_TRACE)
+	l.loggerImpl.swapSinks([]*logSinkEntry{newLogSinkEntry(LOG_SINK_NAME_CAPTURE, c, LOG_LV_TRACE)})
+	l.loggerImpl.start()
+	return l, c
+}
+
+//========================
+//      CapturedLog
+//========================
+type CapturedLog struct {
+	Time   time.Time
+	Lv     LogLv
+	Tag    string
+	Caller string
+	Msg    string     // the message with the fields, eg: "login fail user=bob"
+	Fields []LogField // all the fields, include the fields of the logger
+	Line   string     // the formatted log, without the line break
+}
+
+//========================
+//       LogCapture
+//========================
+
+// A sink which keeps the logs in memory.
+type LogCapture struct {
+	loggerImpl *logger
+	lck        *sync.Mutex
+	logs       []*CapturedLog
+	msgBuf     *logBuffer
+}
+
+// Create a capture, use it by AddLogSink.
+// The queries don't flush any logger, call FlushLogger before them.
+// @return *LogCapture, the capture.
+func NewLogCapture() *LogCapture {
+	return newLogCapture(nil)
+}
+
+func newLogCapture(l *logger) *LogCapture {
+	return &LogCapture{
+		loggerImpl: l,
+		lck:        &sync.Mutex{},
+		logs:       nil,
+		msgBuf:     newLogBuffer(),
+	}
+}
+
+func (c *LogCapture) WriteLogs(logs []*LogInfo) error {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+
+	for _, info := range logs {
+		c.logs = append(c.logs, c.copyLog(info))
+	}
+
+	return nil
+}
+
+func (c *LogCapture) Flush() error {
+	return nil
+}
+
+func (c *LogCapture) Close() error {
+	return nil
+}
+
+// Get all the captured logs, the logs written before the call are included.
+// @return []*CapturedLog, the logs.
+func (c *LogCapture) Logs() []*CapturedLog {
+	return c.Find(LOG_LV_TRACE, "", "")
+}
+
+// Find the captured logs, the logs written before the call are included.
+// @param minLv, the min level.
+// @param tag, the tag, '*' match any sequence of characters, empty mean any tag.
+// @param substr, the sub string of CapturedLog.Msg, empty mean any message.
+// @return []*CapturedLog, the logs.
+func (c *LogCapture) Find(minLv LogLv, tag string, substr string) []*CapturedLog {
+	c.flushLogger()
+
+	c.lck.Lock()
+	defer c.lck.Unlock()
+
+	logs := make([]*CapturedLog, 0, len(c.logs))
+	for _, log := range c.logs {
+		if log.Lv < minLv {
+			continue
+		}
+
+		if len(tag) > 0 && !matchLogTag(tag, log.Tag) {
+			continue
+		}
+
+		if len(substr) > 0 && !strings.Contains(log.Msg, substr) {
+			continue
+		}
+
+		logs = append(logs, log)
+	}
+
+	return logs
+}
+
+// Count the captured logs, see Find.
+// @return int, the count.
+func (c *LogCapture) Count(minLv LogLv, tag string, substr string) int {
+	return len(c.Find(minLv, tag, substr))
+}
+
+// Check if any log is captured, see Find.
+// @return bool, true mean exist.
+func (c *LogCapture) Contains(minLv LogLv, tag string, substr string) bool {
+	return c.Count(minLv, tag, substr) > 0
+}
+
+// Clear the captured logs, the logs written before the call are cleared.
+func (c *LogCapture) Reset() {
+	c.flushLogger()
+
+	c.lck.Lock()
+	defer c.lck.Unlock()
+
+	c.logs = nil
+}
+
+func (c *LogCapture) flushLogger() {
+	if c.loggerImpl != nil {
+		c.loggerImpl.flush()
+	}
+}
+
+// Copy the log, the LogInfo is reused after WriteLogs return.
+// @param info, the log.
+// @return *CapturedLog, the copy.
+func (c *LogCapture) copyLog(info *LogInfo) *CapturedLog {
+	args, fields := info.Args, info.Fields
+	if len(info.Format) == 0 {
+		args, fields, _, _ = splitLogArgsTo(info.Args, info.Fields, nil, nil)
+	}
+
+	w := c.msgBuf
+	w.b = w.b[:0]
+	writeLogMsg(w, info.Format, args, fields)
+
+	return &CapturedLog{
+		Time:   info.Time,
+		Lv:     info.Lv,
+		Tag:    info.Tag,
+		Caller: info.Caller,
+		Msg:    string(w.b),
+		Fields: append([]LogField(nil), fields...),
+		Line:   strings.TrimRight(string(info.LogBuf), "\n"),
+	}
+}
+
+//========================
+//     logger capture
+//========================
+
+// Swap the sinks without closing the old sinks.
+// @param entries, the new sinks.
+// @return []*logSinkEntry, the old sinks.
+func (l *logger) swapSinks(entries []*logSinkEntry) []*logSinkEntry {
+	if l.lckSinks.TryLock(0) != nil {
+		return nil
+	}
+
+	defer l.lckSinks.Unlock()
+
+	old := l.sinks
+	l.sinks = entries
+	return old
+}
+
+func (l *logger) captureLogs() (*LogCapture, func()) {
+	c := newLogCapture(l)
+	if atomic.LoadInt32(&l.bRunning) == 0 {
+		l.start()
+	}
+
+	// write the logs before capturing to the old sinks
+	l.flush()
+	oldLv := l.getLevel()
+	l.SetLevel(LOG_LV_TRACE)
+	oldSinks := l.swapSinks([]*logSinkEntry{newLogSinkEntry(LOG_SINK_NAME_CAPTURE, c, LOG_LV_TRACE)})
+
+	restore := func() {
+		l.flush()
+		l.swapSinks(oldSinks)
+		l.SetLevel(oldLv)
+	}
+
+	return c, restore
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import "testing"
+
+func TestLogCaptureFind(t *testing.T) {
+	l, c := NewCaptureLogger("capture")
+	defer l.StopLogger()
+
+	l.D("debug message")
+	l.I("login ok", LogKV("user", "bob"))
+	l.W("login fail", LogKV("user", "alice"))
+	l.With("conn", 1).E("conn lost")
+
+	logs := c.Logs()
+	if len(logs) != 4 {
+		t.Fatalf("Logs() = %d logs, want 4", len(logs))
+	}
+
+	if logs[1].Lv != LOG_LV_INFO || logs[1].Tag != "capture" || logs[1].Msg != "login ok user=bob" {
+		t.Errorf("logs[1] = %+v", logs[1])
+	}
+
+	if len(logs[1].Fields) != 1 || logs[1].Fields[0].Key != "user" || logs[1].Fields[0].Value != "bob" {
+		t.Errorf("logs[1].Fields = %v", logs[1].Fields)
+	}
+
+	if len(logs[2].Caller) == 0 {
+		t.Errorf("logs[2].Caller is empty, the WARN logs show the caller")
+	}
+
+	found := c.Find(LOG_LV_WARN, "", "")
+	if len(found) != 2 || found[0].Msg != "login fail user=alice" || found[1].Msg != "conn lost conn=1" {
+		t.Errorf("Find(WARN) = %v", found)
+	}
+
+	if n := c.Count(LOG_LV_TRACE, "", "login"); n != 2 {
+		t.Errorf("Count(login) = %d, want 2", n)
+	}
+
+	if n := c.Count(LOG_LV_TRACE, "cap*", ""); n != 4 {
+		t.Errorf("Count(cap*) = %d, want 4", n)
+	}
+
+	if n := c.Count(LOG_LV_TRACE, "other", ""); n != 0 {
+		t.Errorf("Count(other) = %d, want 0", n)
+	}
+
+	if !c.Contains(LOG_LV_ERROR, "", "conn lost") {
+		t.Errorf("Contains(ERROR, conn lost) = false")
+	}
+
+	if c.Contains(LOG_LV_ERROR, "", "login") {
+		t.Errorf("Contains(ERROR, login) = true")
+	}
+}
+
+func TestLogCaptureReset(t *testing.T) {
+	l, c := NewCaptureLogger("capture")
+	defer l.StopLogger()
+
+	l.I("before reset")
+	c.Reset()
+	if n := c.Count(LOG_LV_TRACE, "", ""); n != 0 {
+		t.Fatalf("Count() after Reset = %d, want 0", n)
+	}
+
+	l.I("after reset")
+	logs := c.Logs()
+	if len(logs) != 1 || logs[0].Msg != "after reset" {
+		t.Errorf("Logs() after Reset = %v", logs)
+	}
+}
+
+func TestCaptureLogsRestore(t *testing.T) {
+	l := NewIndependentLogger("restore")
+	orig := NewLogCapture()
+	l.RemoveLogSink(LOG_SINK_NAME_CONSOLE)
+	l.AddLogSink("orig", orig, LOG_LV_TRACE)
+	l.SetLogLevel(LOG_LV_INFO)
+	l.StartLogger()
+	defer l.StopLogger()
+
+	l.I("before capture")
+	c, restore := l.CaptureLogs()
+	l.D("in capture")
+	restore()
+	l.D("after restore, filtered")
+	l.I("after restore")
+	l.FlushLogger()
+
+	if !c.Contains(LOG_LV_TRACE, "", "in capture") || c.Count(LOG_LV_TRACE, "", "") != 1 {
+		t.Errorf("capture logs = %v", c.Logs())
+	}
+
+	logs := orig.Logs()
+	if len(logs) != 2 || logs[0].Msg != "before capture" || logs[1].Msg != "after restore" {
+		t.Errorf("original sink logs = %v", logs)
+	}
+}
+
+func TestCaptureLogsAfterRestart(t *testing.T) {
+	l := NewIndependentLogger("restart")
+	l.RemoveLogSink(LOG_SINK_NAME_CONSOLE)
+	l.StartLogger()
+	l.StopLogger()
+
+	c, restore := l.CaptureLogs()
+	defer l.StopLogger()
+	defer restore()
+
+	l.I("after restart")
+	if !c.Contains(LOG_LV_INFO, "", "after restart") {
+		t.Errorf("capture logs after restart = %v", c.Logs())
+	}
+}
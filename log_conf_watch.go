@@ -0,0 +1,215 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const LOG_CONF_CHECK_INTV = 1000
+
+//========================
+//    logConfWatcher
+//========================
+type logConfWatcher struct {
+	path           string
+	decodeCb       func(data []byte) ([]byte, error)
+	modTime        time.Time
+	size           int64
+	lastCheckTime  time.Time
+	bReloadLoggers bool // reload the named loggers too
+}
+
+func newLogConfWatcher(path string, decodeCb func(data []byte) ([]byte, error)) *logConfWatcher {
+	w := &logConfWatcher{
+		path:           path,
+		decodeCb:       decodeCb,
+		modTime:        time.Time{},
+		size:           0,
+		lastCheckTime:  time.Now(),
+		bReloadLoggers: false,
+	}
+
+	fs, err := os.Stat(path)
+	if err == nil {
+		w.modTime = fs.ModTime()
+		w.size = fs.Size()
+	}
+
+	return w
+}
+
+// Check the config file.
+// @return *LogConf, the new config, nil mean not changed.
+// @return error, error.
+func (w *logConfWatcher) check() (*LogConf, error) {
+	now := time.Now()
+	if now.Sub(w.lastCheckTime) < LOG_CONF_CHECK_INTV*time.Millisecond {
+		return nil, nil
+	}
+
+	w.lastCheckTime = now
+	fs, err := os.Stat(w.path)
+	if err != nil {
+		return nil, err
+	}
+
+	if fs.ModTime().Equal(w.modTime) && fs.Size() == w.size {
+		return nil, nil
+	}
+
+	w.modTime = fs.ModTime()
+	w.size = fs.Size()
+
+	cfg := &LogConf{}
+	err = LoadJsonConf(cfg, w.path, w.decodeCb)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+//========================
+//     logger reload
+//========================
+func (l *logger) configByFile(path string, decodeCb func(data []byte) ([]byte, error), printFunc func(lv LogLv, logStr string)) error {
+	cfg := &LogConf{}
+	err := LoadJsonConf(cfg, path, decodeCb)
+	if err != nil {
+		return err
+	}
+
+	l.config(cfg, printFunc)
+	l.watchConf(path, decodeCb)
+	return nil
+}
+
+func (l *logger) watchConf(path string, decodeCb func(data []byte) ([]byte, error)) {
+	l.confWatcher.Store(newLogConfWatcher(path, decodeCb))
+}
+
+// Watch the config file of the named loggers, which is reloaded in the loop of the global logger.
+// @param path, path of the json file.
+// @param decodeCb, a callback function to decode the content of the file.
+func (l *logger) watchLoggersConf(path string, decodeCb func(data []byte) ([]byte, error)) {
+	w := newLogConfWatcher(path, decodeCb)
+	w.bReloadLoggers = true
+	l.confWatcher.Store(w)
+}
+
+func (l *logger) stopWatchConf() {
+	l.confWatcher.Store((*logConfWatcher)(nil))
+}
+
+// Check the config file in the logger loop, so the files can be reopened safely.
+func (l *logger) checkConf() {
+	// the named logger is reloaded in its own loop too
+	namedConf := l.namedConf.Load().(*LogConf)
+	if namedConf != l.lastNamedConf {
+		l.lastNamedConf = namedConf
+		l.reloadConf(namedConf)
+	}
+
+	w := l.confWatcher.Load().(*logConfWatcher)
+	if w == nil {
+		return
+	}
+
+	cfg, err := w.check()
+	if err != nil {
+		fmt.Println("reload log config error: ", err)
+		return
+	}
+
+	if cfg != nil {
+		l.reloadConf(cfg)
+		if w.bReloadLoggers {
+			loggerRegistry.reloadLoggers(cfg)
+		}
+	}
+}
+
+// Apply the changes of the config, the print function is kept.
+// @param cfg, the new config.
+func (l *logger) reloadConf(cfg *LogConf) {
+	oldCfg := l.curConf.Load().(*LogConf)
+	if oldCfg == nil {
+		oldCfg = &LogConf{}
+	}
+
+	l.applyConf(cfg)
+
+	if len(cfg.Sinks) > 0 {
+		if !l.isSinksConfEqual(oldCfg, cfg) {
+			l.configSinks(cfg)
+		} else if l.isDumpOpen() {
+			l.setDumpParams(cfg.DumpThreshold, cfg.DumpInterval)
+		}
+
+		l.curConf.Store(cfg)
+		return
+	}
+
+	// sinks removed, back to the default outputs
+	if len(oldCfg.Sinks) > 0 {
+		l.setDumpOpen(false)
+		l.setSinks([]*logSinkEntry{newLogSinkEntry(LOG_SINK_NAME_CONSOLE, l.consoleSink, LOG_LV_TRACE)})
+	}
+
+	if cfg.IsDump {
+		if !l.isDumpOpen() || !l.isDumpConfEqual(oldCfg, cfg) {
+			l.startDump(cfg.DumpPath, cfg.DumpFileSize, cfg.DumpThreshold, cfg.DumpInterval, &cfg.LogRotateConf)
+			l.setDumpSplits(cfg.DumpSplits)
+		} else {
+			l.setDumpParams(cfg.DumpThreshold, cfg.DumpInterval)
+		}
+	} else if l.isDumpOpen() {
+		l.stopDump()
+	}
+
+	l.curConf.Store(cfg)
+}
+
+func (l *logger) isDumpConfEqual(oldCfg *LogConf, cfg *LogConf) bool {
+	return oldCfg.DumpPath == cfg.DumpPath &&
+		oldCfg.DumpFileSize == cfg.DumpFileSize &&
+		oldCfg.DumpBakPath == cfg.DumpBakPath &&
+		oldCfg.DumpRetrySize == cfg.DumpRetrySize &&
+		oldCfg.LogRotateConf == cfg.LogRotateConf &&
+		l.isDumpSplitsEqual(oldCfg, cfg)
+}
+
+func (l *logger) isDumpSplitsEqual(oldCfg *LogConf, cfg *LogConf) bool {
+	oldData, err := json.Marshal(oldCfg.DumpSplits)
+	if err != nil {
+		return false
+	}
+
+	data, err := json.Marshal(cfg.DumpSplits)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(oldData, data)
+}
+
+func (l *logger) isSinksConfEqual(oldCfg *LogConf, cfg *LogConf) bool {
+	oldData, err := json.Marshal(oldCfg.Sinks)
+	if err != nil {
+		return false
+	}
+
+	data, err := json.Marshal(cfg.Sinks)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(oldData, data)
+}
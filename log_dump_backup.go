@@ -0,0 +1,381 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	LOG_DUMP_BAK_EXT            = ".bak"
+	LOG_DEFAULT_DUMP_RETRY_SIZE = 10000
+)
+
+//========================
+//    global method
+//========================
+
+// Set the backup of the dump file, take effect at the next StartDumpLog.
+// The logs which fail to dump are kept in memory and re-appended when the dump file is writable again,
+// the oldest logs are moved to the backup file when the memory buffer is full.
+// @param bakFile, the backup file, empty mean the name of the dump file with ".bak" in the working directory.
+// @param retrySize, the max count of logs in memory, 0 mean the default size.
+func SetDumpBackup(bakFile string, retrySize int) {
+	loggerInst.setDumpBackup(bakFile, retrySize)
+}
+
+// Set the callback when dumping is degraded or recovered.
+// The callback is called in the logger goroutine, and should not print logs.
+// @param cb, the callback, err is not nil when degraded and nil when recovered.
+func SetDumpErrorCallback(cb func(file string, err error)) {
+	loggerInst.setDumpErrorCallback(cb)
+}
+
+//========================
+//      logDumpConf
+//========================
+
+// The settings of the dump file, replaced as a whole so the loggers can read it without lock.
+type logDumpConf struct {
+	file      string
+	fileSize  int
+	rotateCfg *LogRotateConf
+	bakFile   string
+	retrySize int
+}
+
+func newLogDumpConf() *logDumpConf {
+	return &logDumpConf{
+		file:      "",
+		fileSize:  LOG_DEFAULT_DUMP_SIZE,
+		rotateCfg: nil,
+		bakFile:   "",
+		retrySize: 0,
+	}
+}
+
+func (c *logDumpConf) clone() *logDumpConf {
+	cp := *c
+	return &cp
+}
+
+// Get the backup file of the dump file, eg: "app.log.bak" in the working directory for "logs/app.log".
+// @return string, the backup file.
+func (c *logDumpConf) getBakFile() string {
+	if len(c.bakFile) == 0 {
+		return filepath.Base(c.file) + LOG_DUMP_BAK_EXT
+	}
+
+	return c.bakFile
+}
+
+//========================
+//     logger backup
+//========================
+func (l *logger) getDumpConf() *logDumpConf {
+	return l.dumpConf.Load().(*logDumpConf)
+}
+
+// Update the dump settings.
+// @param update, the function to change the copy of the settings.
+// @return *logDumpConf, the new settings.
+func (l *logger) updateDumpConf(update func(c *logDumpConf)) *logDumpConf {
+	if l.lckDumpConf.TryLock(0) != nil {
+		return l.getDumpConf()
+	}
+
+	defer l.lckDumpConf.Unlock()
+
+	c := l.getDumpConf().clone()
+	update(c)
+	l.dumpConf.Store(c)
+	return c
+}
+
+func (l *logger) setDumpBackup(bakFile string, retrySize int) {
+	l.updateDumpConf(func(c *logDumpConf) {
+		c.bakFile = bakFile
+		c.retrySize = retrySize
+	})
+}
+
+func (l *logger) setDumpErrorCallback(cb func(file string, err error)) {
+	l.dumpErrCb.Store(cb)
+}
+
+func (l *logger) onDumpError(file string, err error) {
+	cb, _ := l.dumpErrCb.Load().(func(file string, err error))
+	if cb != nil {
+		cb(file, err)
+	}
+}
+
+//========================
+//   FileLogSink backup
+//========================
+
+// Set the backup file and the retry buffer size, must be called before writing logs.
+// @param bakFile, the backup file, empty mean the file name with ".bak" in the working directory.
+// @param retrySize, the max count of logs kept in memory when fail to dump, 0 mean the default size.
+func (s *FileLogSink) SetBackup(bakFile string, retrySize int) {
+	// not in the directory of the file, which may fail for the same reason
+	if len(bakFile) == 0 {
+		bakFile = filepath.Base(s.strFile) + LOG_DUMP_BAK_EXT
+	}
+
+	if retrySize <= 0 {
+		retrySize = LOG_DEFAULT_DUMP_RETRY_SIZE
+	}
+
+	s.bakFile = bakFile
+	s.retrySize = retrySize
+
+	// merge the backup of last run
+	size, err := GetFileSize(bakFile)
+	if err == nil && size > 0 {
+		s.bDegraded = true
+	}
+}
+
+// Set the callback when dumping is degraded or recovered, must be called before writing logs.
+// @param cb, the callback, err is not nil when degraded and nil when recovered.
+func (s *FileLogSink) SetErrorCallback(cb func(file string, err error)) {
+	s.errCb = cb
+}
+
+// Append the backlog logs to the dump file.
+// @return error, error.
+func (s *FileLogSink) recoverBacklog() error {
+	// the backup file is older than the memory buffer
+	size, err := GetFileSize(s.bakFile)
+	if err == nil && size > 0 {
+		err = s.appendBakFile()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.dumpRetryLogs()
+	if err != nil {
+		return err
+	}
+
+	s.retryLogs = make([]string, 0)
+	s.bDegraded = false
+	if s.bErrNotified {
+		s.bErrNotified = false
+		if s.errCb != nil {
+			s.errCb(s.strFile, nil)
+		}
+	}
+
+	return nil
+}
+
+func (s *FileLogSink) appendBakFile() error {
+	src, err := os.Open(s.bakFile)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	err = s.openFile()
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(s.file, src)
+	s.fileSize += n
+	if err != nil {
+		s.closeFile()
+		if n > 0 {
+			src.Close()
+			removeErr := removeFileHead(s.bakFile, n)
+			if removeErr != nil {
+				fmt.Println("remove head of ", s.bakFile, " error: ", removeErr)
+			}
+		}
+
+		return err
+	}
+
+	src.Close()
+	return os.Remove(s.bakFile)
+}
+
+// Append the logs in the retry buffer to the opened dump file.
+// @return error, error.
+func (s *FileLogSink) dumpRetryLogs() error {
+	if len(s.retryLogs) == 0 {
+		return nil
+	}
+
+	err := s.openFile()
+	if err != nil {
+		return err
+	}
+
+	buf := s.buf[:0]
+	for _, str := range s.retryLogs {
+		buf = append(buf, str...)
+	}
+
+	s.buf = buf
+	n, err := s.file.Write(buf)
+	s.fileSize += int64(n)
+	if err != nil {
+		s.closeFile()
+		s.retryLogs = trimWrittenStrs(s.retryLogs, n)
+	}
+
+	return err
+}
+
+// Keep the logs which fail to dump.
+// @param logs, the logs.
+// @param offset, the written bytes of the first log.
+// @param dumpErr, the dump error.
+func (s *FileLogSink) backlog(logs []*LogInfo, offset int, dumpErr error) {
+	s.bDegraded = true
+	if !s.bErrNotified {
+		s.bErrNotified = true
+		if s.errCb != nil {
+			s.errCb(s.strFile, dumpErr)
+		}
+	}
+
+	for i, info := range logs {
+		if i == 0 {
+			s.retryLogs = append(s.retryLogs, string(info.LogBuf[offset:]))
+		} else {
+			s.retryLogs = append(s.retryLogs, string(info.LogBuf))
+		}
+	}
+
+	if len(s.retryLogs) > s.retrySize {
+		s.spillToBak(len(s.retryLogs) - s.retrySize)
+	}
+}
+
+// Move the oldest logs in memory to the backup file.
+// The logs which fail to move are kept in memory, the buffer may exceed the retry size until the backup file is writable.
+// @param cnt, the count of logs.
+func (s *FileLogSink) spillToBak(cnt int) {
+	n, err := s.dumpStrsToFile(s.bakFile, s.retryLogs[:cnt])
+	if err != nil {
+		fmt.Println("dump to ", s.bakFile, " error: ", err)
+	}
+
+	remain := trimWrittenStrs(s.retryLogs, n)
+	capacity := s.retrySize
+	if len(remain) > capacity {
+		capacity = len(remain)
+	}
+
+	retryLogs := make([]string, len(remain), capacity)
+	copy(retryLogs, remain)
+	s.retryLogs = retryLogs
+}
+
+// Append the strings to the file.
+// @param file, the file.
+// @param strs, the strings.
+// @return int, the written bytes.
+// @return error, error.
+func (s *FileLogSink) dumpStrsToFile(file string, strs []string) (int, error) {
+	if len(strs) == 0 {
+		return 0, nil
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return 0, err
+	}
+
+	defer f.Close()
+
+	// one writing, so the written bytes is known when error
+	buf := s.buf[:0]
+	for _, str := range strs {
+		buf = append(buf, str...)
+	}
+
+	s.buf = buf
+	return f.Write(buf)
+}
+
+// Remove the written strings, the partial written string is kept the unwritten tail.
+// @param strs, the strings.
+// @param n, the written bytes.
+// @return []string, the unwritten strings.
+func trimWrittenStrs(strs []string, n int) []string {
+	for len(strs) > 0 && n >= len(strs[0]) {
+		n -= len(strs[0])
+		strs = strs[1:]
+	}
+
+	if n > 0 {
+		strs[0] = strs[0][n:]
+	}
+
+	return strs
+}
+
+// Count the logs which are written completely.
+// @param logs, the logs.
+// @param n, the written bytes.
+// @return int, the count of the complete logs.
+// @return int, the written bytes of the next log.
+func countWrittenLogs(logs []*LogInfo, n int) (int, int) {
+	cnt := 0
+	for cnt < len(logs) && n >= len(logs[cnt].LogBuf) {
+		n -= len(logs[cnt].LogBuf)
+		cnt++
+	}
+
+	return cnt, n
+}
+
+// Remove the head of the file, eg: the copied part of the backup file.
+// @param file, the file.
+// @param n, the size of the head.
+// @return error, error.
+func removeFileHead(file string, n int64) error {
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	_, err = src.Seek(n, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	tmpFile := file + ".tmp"
+	dst, err := os.OpenFile(tmpFile, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	closeErr := dst.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+
+	src.Close()
+	return os.Rename(tmpFile, file)
+}
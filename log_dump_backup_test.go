@@ -0,0 +1,101 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Change the working directory to a temporary directory until the test ends.
+func chdirTemp(t *testing.T) string {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		os.Chdir(wd)
+	})
+
+	return dir
+}
+
+func readTestFile(t *testing.T, file string) string {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestDumpDefaultBakFile(t *testing.T) {
+	cases := []struct {
+		conf logDumpConf
+		want string
+	}{
+		{logDumpConf{file: "logs/app.log"}, "app.log.bak"},
+		{logDumpConf{file: "app"}, "app.bak"},
+		{logDumpConf{file: "logs/app.log", bakFile: "bak/my.bak"}, "bak/my.bak"},
+	}
+
+	for _, c := range cases {
+		if got := c.conf.getBakFile(); got != c.want {
+			t.Errorf("getBakFile(%q, %q) = %q, want %q", c.conf.file, c.conf.bakFile, got, c.want)
+		}
+	}
+}
+
+func TestDumpBackupPerLogger(t *testing.T) {
+	dir := chdirTemp(t)
+	names := []string{"alpha", "beta"}
+	for _, name := range names {
+		// the backlog of the last run
+		err := ioutil.WriteFile(name+".log"+LOG_DUMP_BAK_EXT, []byte("backlog of "+name+"\n"), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range names {
+		logDir := filepath.Join(dir, "logs_"+name)
+		err := os.Mkdir(logDir, 0777)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		l := NewIndependentLogger(name)
+		l.StartDumpLogDefault(filepath.Join(logDir, name+".log"))
+		l.StartLogger()
+		l.I("log of " + name)
+		l.StopLogger()
+	}
+
+	for i, name := range names {
+		content := readTestFile(t, filepath.Join(dir, "logs_"+name, name+".log"))
+		if !strings.Contains(content, "backlog of "+name+"\n") || !strings.Contains(content, "log of "+name) {
+			t.Errorf("%s.log = %q, want its own backlog and log", name, content)
+		}
+
+		other := names[1-i]
+		if strings.Contains(content, "backlog of "+other) {
+			t.Errorf("%s.log = %q, contain the backlog of %s", name, content, other)
+		}
+
+		if _, err := os.Stat(name + ".log" + LOG_DUMP_BAK_EXT); !os.IsNotExist(err) {
+			t.Errorf("%s.log.bak is not removed after recovery, err = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const LOG_SINK_NAME_DUMP_SPLIT_PREFIX = LOG_SINK_NAME_DUMP + "."
+
+var ErrLogDumpNotStart = errors.New("log dump not start")
+
+//========================
+//      LogDumpSplit
+//========================
+
+// An extra dump file which only keep the logs not lower than the level, eg: "app.error.log" for WARN+.
+type LogDumpSplit struct {
+	Name     string         `json:"name"`      // eg: "error" mean "app.error.log" for the dump file "app.log"
+	Path     string         `json:"path"`      // empty mean insert the name before the extension of the dump file
+	Level    int            `json:"level"`     // min level
+	FileSize int            `json:"file_size"` // 0 mean the size of the dump file
+	Rotate   *LogRotateConf `json:"rotate"`    // nil mean the same as the dump file
+}
+
+//========================
+//    global method
+//========================
+
+// Add a level-split dump file, must be called after StartDumpLog.
+// @param split, the split config.
+// @return error, error.
+func AddLogDumpSplit(split *LogDumpSplit) error {
+	return loggerInst.addDumpSplit(split)
+}
+
+// Remove a level-split dump file.
+// @param name, the name of the split.
+func RemoveLogDumpSplit(name string) {
+	loggerInst.removeSink(LOG_SINK_NAME_DUMP_SPLIT_PREFIX + name)
+}
+
+// Get the path of the split file, eg: "logs/app.error.log" for "logs/app.log".
+// @param file, the dump file.
+// @param name, the name of the split.
+// @return string, the path.
+func getDumpSplitPath(file string, name string) string {
+	ext := filepath.Ext(file)
+	return file[:len(file)-len(ext)] + "." + name + ext
+}
+
+//========================
+//   logger dump split
+//========================
+func (l *logger) newDumpSplitSink(split *LogDumpSplit) (*FileLogSink, error) {
+	if len(split.Name) == 0 {
+		return nil, ErrLogNameIsEmpty
+	}
+
+	dumpConf := l.getDumpConf()
+	path := split.Path
+	if len(path) == 0 {
+		if len(dumpConf.file) == 0 {
+			return nil, ErrLogDumpNotStart
+		}
+
+		path = getDumpSplitPath(dumpConf.file, split.Name)
+	}
+
+	fileSize := split.FileSize
+	if fileSize <= 0 {
+		fileSize = dumpConf.fileSize
+	}
+
+	rotateCfg := split.Rotate
+	if rotateCfg == nil {
+		rotateCfg = dumpConf.rotateCfg
+	}
+
+	sink := NewFileLogSink(path, fileSize)
+	if rotateCfg != nil {
+		sink.SetRotateConf(rotateCfg)
+	}
+
+	// eg: "app.error.log.bak" for "app.error.log", or "my.error.bak" for the backup "my.bak"
+	bakFile := ""
+	if len(dumpConf.bakFile) > 0 {
+		bakFile = getDumpSplitPath(dumpConf.bakFile, split.Name)
+	}
+
+	sink.SetBackup(bakFile, dumpConf.retrySize)
+	sink.SetErrorCallback(l.onDumpError)
+	return sink, nil
+}
+
+func (l *logger) addDumpSplit(split *LogDumpSplit) error {
+	if !l.isDumpOpen() {
+		return ErrLogDumpNotStart
+	}
+
+	sink, err := l.newDumpSplitSink(split)
+	if err != nil {
+		return err
+	}
+
+	l.addSink(LOG_SINK_NAME_DUMP_SPLIT_PREFIX+split.Name, sink, split.Level)
+	return nil
+}
+
+// Replace all the level-split dump files.
+// @param splits, the split configs.
+func (l *logger) setDumpSplits(splits []*LogDumpSplit) {
+	l.removeDumpSplits()
+	for _, split := range splits {
+		err := l.addDumpSplit(split)
+		if err != nil {
+			fmt.Println("add log dump split ", split.Name, " error: ", err)
+		}
+	}
+}
+
+func (l *logger) removeDumpSplits() {
+	if l.lckSinks.TryLock(0) != nil {
+		return
+	}
+
+	defer l.lckSinks.Unlock()
+
+	sinks := make([]*logSinkEntry, 0, len(l.sinks))
+	for _, entry := range l.sinks {
+		if strings.HasPrefix(entry.name, LOG_SINK_NAME_DUMP_SPLIT_PREFIX) {
+			l.removedSinks = append(l.removedSinks, entry)
+		} else {
+			sinks = append(sinks, entry)
+		}
+	}
+
+	l.sinks = sinks
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetDumpSplitPath(t *testing.T) {
+	cases := []struct {
+		file string
+		want string
+	}{
+		{"logs/app.log", "logs/app.error.log"},
+		{"app.log", "app.error.log"},
+		{"app", "app.error"},
+		{"logs.d/app", "logs.d/app.error"},
+		{"app.tar.gz", "app.tar.error.gz"},
+		{"app.log.bak", "app.log.error.bak"},
+	}
+
+	for _, c := range cases {
+		if got := getDumpSplitPath(c.file, "error"); got != c.want {
+			t.Errorf("getDumpSplitPath(%q) = %q, want %q", c.file, got, c.want)
+		}
+	}
+}
+
+func TestLogDumpSplit(t *testing.T) {
+	dir := chdirTemp(t)
+	file := filepath.Join(dir, "app.log")
+
+	l := NewIndependentLogger("split")
+	l.SetLogLevel(LOG_LV_DEBUG)
+	if err := l.AddLogDumpSplit(&LogDumpSplit{Name: "error", Level: LOG_LV_WARN}); err != ErrLogDumpNotStart {
+		t.Errorf("AddLogDumpSplit() before dump = %v, want %v", err, ErrLogDumpNotStart)
+	}
+
+	l.StartDumpLogDefault(file)
+	if err := l.AddLogDumpSplit(&LogDumpSplit{Name: "error", Level: LOG_LV_WARN}); err != nil {
+		t.Fatal(err)
+	}
+
+	l.StartLogger()
+	l.D("debug line")
+	l.I("info line")
+	l.W("warn line")
+	l.E("error line")
+	l.StopLogger()
+
+	content := readTestFile(t, file)
+	for _, line := range []string{"debug line", "info line", "warn line", "error line"} {
+		if !strings.Contains(content, line) {
+			t.Errorf("app.log = %q, want %q", content, line)
+		}
+	}
+
+	content = readTestFile(t, filepath.Join(dir, "app.error.log"))
+	if !strings.Contains(content, "warn line") || !strings.Contains(content, "error line") {
+		t.Errorf("app.error.log = %q, want the WARN+ logs", content)
+	}
+
+	if strings.Contains(content, "debug line") || strings.Contains(content, "info line") {
+		t.Errorf("app.error.log = %q, contain the logs below WARN", content)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+const (
+	LOG_FSYNC_NONE   = "none"   // never fsync
+	LOG_FSYNC_FLUSH  = "flush"  // fsync when flush explicitly
+	LOG_FSYNC_ALWAYS = "always" // fsync after every dump
+)
+
+// A sink which can commit the written logs to the stable storage.
+type LogSyncer interface {
+	// Sync the written logs to the stable storage.
+	// @return error, error.
+	Sync() error
+}
+
+var runningLoggers = make(map[*logger]bool)
+var lckRunningLoggers = NewFastLock()
+
+//========================
+//    global method
+//========================
+
+// Flush the logger, block until all the logs queued before the call are written.
+func FlushLogger() {
+	loggerInst.flush()
+}
+
+// Flush all the running loggers, include the independent loggers.
+func FlushAllLoggers() {
+	for _, l := range getRunningLoggers() {
+		l.flush()
+	}
+}
+
+// Flush all the running loggers and exit.
+// @param code, the exit code.
+func FlushAndExit(code int) {
+	FlushAllLoggers()
+	os.Exit(code)
+}
+
+// Set the fsync policy.
+// @param policy, LOG_FSYNC_XXX.
+func SetLogFsyncPolicy(policy string) {
+	loggerInst.setFsyncPolicy(policy)
+}
+
+func addRunningLogger(l *logger) {
+	if lckRunningLoggers.TryLock(0) != nil {
+		return
+	}
+
+	defer lckRunningLoggers.Unlock()
+
+	runningLoggers[l] = true
+}
+
+func removeRunningLogger(l *logger) {
+	if lckRunningLoggers.TryLock(0) != nil {
+		return
+	}
+
+	defer lckRunningLoggers.Unlock()
+
+	delete(runningLoggers, l)
+}
+
+func getRunningLoggers() []*logger {
+	if lckRunningLoggers.TryLock(0) != nil {
+		return nil
+	}
+
+	defer lckRunningLoggers.Unlock()
+
+	loggers := make([]*logger, 0, len(runningLoggers))
+	for l := range runningLoggers {
+		loggers = append(loggers, l)
+	}
+
+	return loggers
+}
+
+//========================
+//     logger flush
+//========================
+func (l *logger) start() {
+	// the writer may be started by CaptureLogs already
+	if !atomic.CompareAndSwapInt32(&l.bRunning, 0, 1) {
+		return
+	}
+
+	addRunningLogger(l)
+	go l.loop()
+}
+
+func (l *logger) isRunning() bool {
+	return atomic.LoadInt32(&l.bRunning) == 1 && !l.evtStopSucc.IsClose()
+}
+
+func (l *logger) setFsyncPolicy(policy string) {
+	if len(policy) == 0 {
+		policy = LOG_FSYNC_NONE
+	}
+
+	l.fsyncPolicy.Store(policy)
+}
+
+// Push a flush mark and wait the writer to handle it.
+func (l *logger) flush() {
+	if !l.isRunning() {
+		return
+	}
+
+	// the mark is released by the writer, keep the event
+	evt := NewEvent()
+	info := newLogInfo(LOG_LV_INFO, "", nil, nil, false)
+	info.flushEvt = evt
+
+	// l.lck.Lock()
+	if l.lck.TryLock(0) != nil {
+		return
+	}
+
+	l.pushOneLog(info)
+	l.lck.Unlock()
+
+	// the writer can't wait itself, eg: a sink callback flush, the mark is written right after the current logs
+	if l.isWriterGoroutine() {
+		atomic.StoreInt32(&l.bFlushPending, 1)
+		return
+	}
+
+	for {
+		// broadcast again if the writer missed it
+		l.evtDumpToFile.Broadcast()
+		err := evt.WaitUntilTimeout(LOG_DEFAULT_DUMP_INTV)
+		if err != ErrEvtWaitTimeout || !l.isRunning() {
+			break
+		}
+	}
+}
+
+// Check if the current goroutine is the writer of the logger.
+// @return bool, true mean in the writer goroutine.
+func (l *logger) isWriterGoroutine() bool {
+	gid := atomic.LoadUint64(&l.writerGid)
+	return gid != 0 && gid == getGoroutineId()
+}
+
+// Take the flush marks out of the write logs.
+// @return []*Event, the events of the flush marks.
+func (l *logger) takeFlushEvents() []*Event {
+	var evts []*Event = nil
+	cnt := 0
+	for _, info := range l.writeLogs {
+		if info.flushEvt != nil {
+			evts = append(evts, info.flushEvt)
+			releaseLogInfo(info)
+		} else {
+			l.writeLogs[cnt] = info
+			cnt++
+		}
+	}
+
+	for i := cnt; i < len(l.writeLogs); i++ {
+		l.writeLogs[i] = nil
+	}
+
+	l.writeLogs = l.writeLogs[:cnt]
+	return evts
+}
+
+// Flush the sinks and fsync by the policy.
+// @param sinks, the sinks.
+// @param bExplicit, true mean flush explicitly.
+func (l *logger) flushSinks(sinks []*logSinkEntry, bExplicit bool) {
+	policy := l.fsyncPolicy.Load().(string)
+	bSync := (policy == LOG_FSYNC_ALWAYS || (bExplicit && policy == LOG_FSYNC_FLUSH))
+	for _, entry := range sinks {
+		err := entry.sink.Flush()
+		if err != nil {
+			fmt.Println("flush log sink ", entry.name, " error: ", err)
+		}
+
+		if !bSync {
+			continue
+		}
+
+		syncer, ok := entry.sink.(LogSyncer)
+		if !ok {
+			continue
+		}
+
+		err = syncer.Sync()
+		if err != nil {
+			fmt.Println("sync log sink ", entry.name, " error: ", err)
+		}
+	}
+}
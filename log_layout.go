@@ -0,0 +1,321 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const LOG_DEFAULT_TIME_FORMAT = "YY/MM/DD hh:mm:ss.SSS"
+
+var (
+	ErrLogLayoutUnknownToken = errors.New("unknown log layout token")
+	ErrLogLayoutNotClosed    = errors.New("log layout token not closed")
+)
+
+var logDefaultTimeParts = compileLogTimeFormat(LOG_DEFAULT_TIME_FORMAT)
+
+const (
+	logLayoutLiteral = iota
+	logLayoutTime
+	logLayoutLevel
+	logLayoutTag
+	logLayoutCaller
+	logLayoutFunc
+	logLayoutGid
+	logLayoutMsg
+)
+
+const (
+	logTimeLiteral = iota
+	logTimeYear
+	logTimeMonth
+	logTimeDay
+	logTimeHour
+	logTimeMinute
+	logTimeSecond
+	logTimeMilli
+	logTimeMicro
+)
+
+//========================
+//      logTimePart
+//========================
+type logTimePart struct {
+	kind    int
+	literal string
+}
+
+// Compile the time format, YY(year) MM(month) DD(day) hh(hour) mm(minute) ss(second) SSS(millisecond) SSSSSS(microsecond).
+// @param format, the time format.
+// @return []*logTimePart, the compiled parts.
+func compileLogTimeFormat(format string) []*logTimePart {
+	tokens := []struct {
+		str  string
+		kind int
+	}{
+		{"SSSSSS", logTimeMicro},
+		{"SSS", logTimeMilli},
+		{"YY", logTimeYear},
+		{"MM", logTimeMonth},
+		{"DD", logTimeDay},
+		{"hh", logTimeHour},
+		{"mm", logTimeMinute},
+		{"ss", logTimeSecond},
+	}
+
+	parts := make([]*logTimePart, 0)
+	literal := &strings.Builder{}
+	for len(format) > 0 {
+		bMatch := false
+		for _, token := range tokens {
+			if strings.HasPrefix(format, token.str) {
+				if literal.Len() > 0 {
+					parts = append(parts, &logTimePart{kind: logTimeLiteral, literal: literal.String()})
+					literal.Reset()
+				}
+
+				parts = append(parts, &logTimePart{kind: token.kind})
+				format = format[len(token.str):]
+				bMatch = true
+				break
+			}
+		}
+
+		if !bMatch {
+			literal.WriteByte(format[0])
+			format = format[1:]
+		}
+	}
+
+	if literal.Len() > 0 {
+		parts = append(parts, &logTimePart{kind: logTimeLiteral, literal: literal.String()})
+	}
+
+	return parts
+}
+
+func formatLogTime(parts []*logTimePart, t time.Time, buf []byte) []byte {
+	for _, part := range parts {
+		switch part.kind {
+		case logTimeLiteral:
+			buf = append(buf, part.literal...)
+		case logTimeYear:
+			buf = appendLogUint(buf, uint64(t.Year()), 0)
+		case logTimeMonth:
+			buf = appendLogUint(buf, uint64(t.Month()), 2)
+		case logTimeDay:
+			buf = appendLogUint(buf, uint64(t.Day()), 2)
+		case logTimeHour:
+			buf = appendLogUint(buf, uint64(t.Hour()), 2)
+		case logTimeMinute:
+			buf = appendLogUint(buf, uint64(t.Minute()), 2)
+		case logTimeSecond:
+			buf = appendLogUint(buf, uint64(t.Second()), 2)
+		case logTimeMilli:
+			buf = appendLogUint(buf, uint64(t.Nanosecond()/int(time.Millisecond)), 3)
+		case logTimeMicro:
+			buf = appendLogUint(buf, uint64(t.Nanosecond()/int(time.Microsecond)), 6)
+		}
+	}
+
+	return buf
+}
+
+//========================
+//     logLayoutPart
+//========================
+type logLayoutPart struct {
+	kind      int
+	literal   string
+	timeParts []*logTimePart
+	bUtc      bool
+}
+
+//========================
+//       logLayout
+//========================
+type logLayout struct {
+	parts       []*logLayoutPart
+	bNeedCaller bool
+	bNeedFunc   bool
+	bNeedGid    bool
+}
+
+// Compile the layout pattern.
+// tokens:
+//   {time} or {time:YY/MM/DD hh:mm:ss.SSS}, local time.
+//   {utctime} or {utctime:YY-MM-DD hh:mm:ss.SSSSSS}, utc time.
+//   {level}, {tag}, {caller}(file:line), {func}, {gid}(goroutine id), {msg}(message and fields).
+//   {{ , a literal '{'.
+// eg: [{time:YY/MM/DD hh:mm:ss.SSS}] [{level}] [{tag}] {msg}
+// @param pattern, the layout pattern.
+// @return *logLayout, the compiled layout.
+// @return error, error.
+func compileLogLayout(pattern string) (*logLayout, error) {
+	ly := &logLayout{
+		parts:       make([]*logLayoutPart, 0),
+		bNeedCaller: false,
+		bNeedFunc:   false,
+		bNeedGid:    false,
+	}
+
+	literal := &strings.Builder{}
+	for len(pattern) > 0 {
+		if strings.HasPrefix(pattern, "{{") {
+			literal.WriteByte('{')
+			pattern = pattern[2:]
+			continue
+		}
+
+		if pattern[0] != '{' {
+			literal.WriteByte(pattern[0])
+			pattern = pattern[1:]
+			continue
+		}
+
+		endIdx := strings.IndexByte(pattern, '}')
+		if endIdx < 0 {
+			return nil, ErrLogLayoutNotClosed
+		}
+
+		part, err := ly.compileToken(pattern[1:endIdx])
+		if err != nil {
+			return nil, err
+		}
+
+		if literal.Len() > 0 {
+			ly.parts = append(ly.parts, &logLayoutPart{kind: logLayoutLiteral, literal: literal.String()})
+			literal.Reset()
+		}
+
+		ly.parts = append(ly.parts, part)
+		pattern = pattern[endIdx+1:]
+	}
+
+	if literal.Len() > 0 {
+		ly.parts = append(ly.parts, &logLayoutPart{kind: logLayoutLiteral, literal: literal.String()})
+	}
+
+	return ly, nil
+}
+
+func (ly *logLayout) compileToken(token string) (*logLayoutPart, error) {
+	name := token
+	arg := ""
+	idx := strings.IndexByte(token, ':')
+	if idx >= 0 {
+		name = token[:idx]
+		arg = token[idx+1:]
+	}
+
+	part := &logLayoutPart{}
+	switch name {
+	case "time", "utctime":
+		if len(arg) == 0 {
+			arg = LOG_DEFAULT_TIME_FORMAT
+		}
+
+		part.kind = logLayoutTime
+		part.timeParts = compileLogTimeFormat(arg)
+		part.bUtc = (name == "utctime")
+
+	case "level":
+		part.kind = logLayoutLevel
+
+	case "tag":
+		part.kind = logLayoutTag
+
+	case "caller":
+		part.kind = logLayoutCaller
+		ly.bNeedCaller = true
+
+	case "func":
+		part.kind = logLayoutFunc
+		ly.bNeedCaller = true
+		ly.bNeedFunc = true
+
+	case "gid":
+		part.kind = logLayoutGid
+		ly.bNeedGid = true
+
+	case "msg":
+		part.kind = logLayoutMsg
+
+	default:
+		return nil, ErrLogLayoutUnknownToken
+	}
+
+	return part, nil
+}
+
+// Format the log header and message.
+// @param info, the log.
+// @param lvStr, the level string.
+// @param msg, the message and fields.
+// @param buf, the buffer.
+// @return []byte, the buffer.
+func (ly *logLayout) format(info *LogInfo, lvStr string, msg []byte, buf []byte) []byte {
+	t := info.Time
+	for _, part := range ly.parts {
+		switch part.kind {
+		case logLayoutLiteral:
+			buf = append(buf, part.literal...)
+
+		case logLayoutTime:
+			if part.bUtc {
+				buf = formatLogTime(part.timeParts, t.UTC(), buf)
+			} else {
+				buf = formatLogTime(part.timeParts, t, buf)
+			}
+
+		case logLayoutLevel:
+			buf = append(buf, lvStr...)
+
+		case logLayoutTag:
+			buf = append(buf, info.Tag...)
+
+		case logLayoutCaller:
+			buf = append(buf, info.Caller...)
+
+		case logLayoutFunc:
+			buf = append(buf, info.Func...)
+
+		case logLayoutGid:
+			buf = strconv.AppendUint(buf, info.Gid, 10)
+
+		case logLayoutMsg:
+			buf = append(buf, msg...)
+		}
+	}
+
+	return buf
+}
+
+// Get the id of current goroutine.
+// @return uint64, the goroutine id.
+func getGoroutineId() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+
+	// goroutine 18 [running]:
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	idx := bytes.IndexByte(buf, ' ')
+	if idx < 0 {
+		return 0
+	}
+
+	id, err := strconv.ParseUint(string(buf[:idx]), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
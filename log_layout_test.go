@@ -0,0 +1,120 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatLogTime(t *testing.T) {
+	tm := time.Date(2022, 1, 2, 3, 4, 5, 6007008, time.UTC)
+	cases := []struct {
+		format string
+		want   string
+	}{
+		{LOG_DEFAULT_TIME_FORMAT, "2022/01/02 03:04:05.006"},
+		{"YY-MM-DD hh:mm:ss.SSSSSS", "2022-01-02 03:04:05.006007"},
+		{"hhmmss", "030405"},
+		{"at hh o'clock", "at 03 o'clock"},
+		{"", ""},
+	}
+
+	for _, c := range cases {
+		got := string(formatLogTime(compileLogTimeFormat(c.format), tm, nil))
+		if got != c.want {
+			t.Errorf("format %q = %q, want %q", c.format, got, c.want)
+		}
+	}
+}
+
+func TestCompileLogLayout(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	info := &LogInfo{
+		Time:   time.Date(2022, 1, 2, 11, 4, 5, 6000000, loc),
+		Tag:    "net",
+		Caller: "conn.go:12",
+		Func:   "net.(*Conn).Read",
+		Gid:    18,
+	}
+
+	cases := []struct {
+		pattern     string
+		want        string
+		bNeedCaller bool
+		bNeedFunc   bool
+		bNeedGid    bool
+	}{
+		{"[{time}] [{level}] [{tag}] {msg}", "[2022/01/02 11:04:05.006] [INFO ] [net] hello", false, false, false},
+		{"{time:hh:mm:ss} {msg}", "11:04:05 hello", false, false, false},
+		{"{utctime:YY-MM-DD hh:mm} {msg}", "2022-01-02 03:04 hello", false, false, false},
+		{"{caller} {msg}", "conn.go:12 hello", true, false, false},
+		{"{func}: {msg}", "net.(*Conn).Read: hello", true, true, false},
+		{"[{gid}] {msg}", "[18] hello", false, false, true},
+		{"{{tag} {msg}}", "{tag} hello}", false, false, false},
+		{"{{{tag}", "{net", false, false, false},
+		{"no token", "no token", false, false, false},
+	}
+
+	for _, c := range cases {
+		ly, err := compileLogLayout(c.pattern)
+		if err != nil {
+			t.Errorf("compileLogLayout(%q) error = %v", c.pattern, err)
+			continue
+		}
+
+		got := string(ly.format(info, "INFO ", []byte("hello"), nil))
+		if got != c.want {
+			t.Errorf("layout %q = %q, want %q", c.pattern, got, c.want)
+		}
+
+		if ly.bNeedCaller != c.bNeedCaller || ly.bNeedFunc != c.bNeedFunc || ly.bNeedGid != c.bNeedGid {
+			t.Errorf("layout %q need caller/func/gid = %v/%v/%v, want %v/%v/%v", c.pattern,
+				ly.bNeedCaller, ly.bNeedFunc, ly.bNeedGid, c.bNeedCaller, c.bNeedFunc, c.bNeedGid)
+		}
+	}
+}
+
+func TestCompileLogLayoutError(t *testing.T) {
+	cases := []struct {
+		pattern string
+		err     error
+	}{
+		{"{msg", ErrLogLayoutNotClosed},
+		{"[{level}] {msg", ErrLogLayoutNotClosed},
+		{"{message}", ErrLogLayoutUnknownToken},
+		{"{}", ErrLogLayoutUnknownToken},
+		{"{ msg}", ErrLogLayoutUnknownToken},
+	}
+
+	for _, c := range cases {
+		if _, err := compileLogLayout(c.pattern); err != c.err {
+			t.Errorf("compileLogLayout(%q) error = %v, want %v", c.pattern, err, c.err)
+		}
+	}
+}
+
+func TestLoggerLayout(t *testing.T) {
+	l := NewIndependentLogger("layout")
+	c := NewLogCapture()
+	l.RemoveLogSink(LOG_SINK_NAME_CONSOLE)
+	l.AddLogSink("capture", c, LOG_LV_TRACE)
+	if err := l.loggerImpl.SetLayout("{level}|{tag}|{msg}"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.loggerImpl.SetLayout("{bad}"); err != ErrLogLayoutUnknownToken {
+		t.Errorf("SetLayout({bad}) = %v, want %v", err, ErrLogLayoutUnknownToken)
+	}
+
+	l.StartLogger()
+	l.W("hello", LogKV("user", "bob"))
+	l.StopLogger()
+
+	logs := c.Logs()
+	if len(logs) != 1 || logs[0].Line != "WARN|layout|hello user=bob" {
+		t.Errorf("logs = %+v, want the line in the layout", logs)
+	}
+}
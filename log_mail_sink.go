@@ -0,0 +1,277 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	LOG_SINK_TYPE_MAIL = "mail"
+
+	LOG_MAIL_DEFAULT_WINDOW     = 60000
+	LOG_MAIL_DEFAULT_MAX_LINES  = 200
+	LOG_MAIL_DEFAULT_MAX_RETRY  = 3
+	LOG_MAIL_DEFAULT_RETRY_INTV = 5000
+	LOG_MAIL_DEFAULT_SUBJECT    = "log alert"
+	LOG_MAIL_CHECK_INTV         = 1000
+)
+
+var (
+	ErrLogMailConfIsNil = errors.New("log mail config is nil")
+)
+
+//========================
+//      LogMailConf
+//========================
+type LogMailConf struct {
+	User          string   `json:"user"`
+	AliasName     string   `json:"alias_name"`
+	Pwd           string   `json:"pwd"`
+	Host          string   `json:"host"`
+	Port          int      `json:"port"`
+	To            []string `json:"to"`
+	Subject       string   `json:"subject"`
+	Window        uint32   `json:"window"`         // millisecond, collect the logs in the window to one mail
+	MinInterval   uint32   `json:"min_interval"`   // millisecond, the min interval between two mails, 0 mean same as the window
+	MaxLines      int      `json:"max_lines"`      // max lines in one mail, the rest are counted only
+	MaxRetry      int      `json:"max_retry"`      // retry times when send fail, -1 mean no retry
+	RetryInterval uint32   `json:"retry_interval"` // millisecond
+}
+
+//========================
+//      MailLogSink
+//========================
+type MailLogSink struct {
+	cfg          LogMailConf
+	sendFunc     func(m *Mail) error
+	lck          *FastLock
+	lines        []string
+	omitCnt      uint64
+	firstTime    time.Time
+	lastSendTime time.Time
+	evtStop      *Event
+	evtStopSucc  *Event
+}
+
+// Create a sink which send the logs to mails, the logs in a window are sent as one digest.
+// @param cfg, the mail config, the zero values mean the default values.
+// @return *MailLogSink, the sink.
+func NewMailLogSink(cfg *LogMailConf) *MailLogSink {
+	s := &MailLogSink{
+		cfg:          *cfg,
+		sendFunc:     SendMail,
+		lck:          NewFastLock(),
+		lines:        make([]string, 0),
+		omitCnt:      0,
+		firstTime:    time.Time{},
+		lastSendTime: time.Time{},
+		evtStop:      NewEvent(),
+		evtStopSucc:  NewEvent(),
+	}
+
+	if s.cfg.Window == 0 {
+		s.cfg.Window = LOG_MAIL_DEFAULT_WINDOW
+	}
+
+	if s.cfg.MinInterval == 0 {
+		s.cfg.MinInterval = s.cfg.Window
+	}
+
+	if s.cfg.MaxLines <= 0 {
+		s.cfg.MaxLines = LOG_MAIL_DEFAULT_MAX_LINES
+	}
+
+	if s.cfg.MaxRetry == 0 {
+		s.cfg.MaxRetry = LOG_MAIL_DEFAULT_MAX_RETRY
+	}
+
+	if s.cfg.RetryInterval == 0 {
+		s.cfg.RetryInterval = LOG_MAIL_DEFAULT_RETRY_INTV
+	}
+
+	if len(s.cfg.Subject) == 0 {
+		s.cfg.Subject = LOG_MAIL_DEFAULT_SUBJECT
+	}
+
+	go s.loop()
+	return s
+}
+
+// Set the function to send the mail, SendMail by default.
+// @param sendFunc, the send function.
+func (s *MailLogSink) SetSendFunc(sendFunc func(m *Mail) error) {
+	if s.lck.TryLock(0) != nil {
+		return
+	}
+
+	defer s.lck.Unlock()
+
+	s.sendFunc = sendFunc
+}
+
+func (s *MailLogSink) WriteLogs(logs []*LogInfo) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	if s.lck.TryLock(0) != nil {
+		return ErrTryLockFail
+	}
+
+	defer s.lck.Unlock()
+
+	if len(s.lines) == 0 && s.omitCnt == 0 {
+		s.firstTime = time.Now()
+	}
+
+	for _, info := range logs {
+		if len(s.lines) < s.cfg.MaxLines {
+			s.lines = append(s.lines, string(info.LogBuf))
+		} else {
+			s.omitCnt++
+		}
+	}
+
+	return nil
+}
+
+// The logs are sent by window, so nothing to flush.
+func (s *MailLogSink) Flush() error {
+	return nil
+}
+
+// Send the collected logs and stop the sink.
+func (s *MailLogSink) Close() error {
+	s.evtStop.Close()
+	s.evtStopSucc.Wait()
+	return nil
+}
+
+func (s *MailLogSink) loop() {
+	for {
+		bStop := s.evtStop.IsClose()
+		m, cnt := s.takeDigest(bStop)
+		if m != nil {
+			s.send(m, cnt)
+		}
+
+		if bStop {
+			break
+		}
+
+		s.evtStop.WaitUntilTimeout(LOG_MAIL_CHECK_INTV)
+	}
+
+	s.evtStopSucc.Close()
+}
+
+// Take the collected logs to a mail if the window is closed.
+// @param bForce, true mean take the logs at once.
+// @return *Mail, the mail, nil mean nothing to send.
+// @return uint64, the count of logs in the mail, include the omitted logs.
+func (s *MailLogSink) takeDigest(bForce bool) (*Mail, uint64) {
+	if s.lck.TryLock(0) != nil {
+		return nil, 0
+	}
+
+	defer s.lck.Unlock()
+
+	if len(s.lines) == 0 && s.omitCnt == 0 {
+		return nil, 0
+	}
+
+	now := time.Now()
+	if !bForce {
+		if now.Sub(s.firstTime) < time.Duration(s.cfg.Window)*time.Millisecond {
+			return nil, 0
+		}
+
+		if now.Sub(s.lastSendTime) < time.Duration(s.cfg.MinInterval)*time.Millisecond {
+			return nil, 0
+		}
+	}
+
+	cnt := uint64(len(s.lines)) + s.omitCnt
+	m := s.buildMail(s.lines, s.omitCnt)
+	s.lines = make([]string, 0)
+	s.omitCnt = 0
+	s.lastSendTime = now
+	return m, cnt
+}
+
+func (s *MailLogSink) buildMail(lines []string, omitCnt uint64) *Mail {
+	host, _ := os.Hostname()
+	total := uint64(len(lines)) + omitCnt
+
+	body := &strings.Builder{}
+	body.WriteString("<pre>")
+	for _, line := range lines {
+		body.WriteString(html.EscapeString(line))
+	}
+
+	if omitCnt > 0 {
+		body.WriteString("... ")
+		body.WriteString(strconv.FormatUint(omitCnt, 10))
+		body.WriteString(" more logs omitted\n")
+	}
+
+	body.WriteString("</pre>")
+
+	return &Mail{
+		User:      s.cfg.User,
+		AliasName: s.cfg.AliasName,
+		Pwd:       s.cfg.Pwd,
+		Host:      s.cfg.Host,
+		Port:      s.cfg.Port,
+		To:        s.cfg.To,
+		Subject:   s.cfg.Subject + " - " + host + " (" + strconv.FormatUint(total, 10) + " logs)",
+		Body:      body.String(),
+	}
+}
+
+// Send the mail, retry when fail, no retry after the sink is closed.
+// @param m, the mail.
+// @param cnt, the count of logs in the mail.
+func (s *MailLogSink) send(m *Mail, cnt uint64) {
+	if s.lck.TryLock(0) != nil {
+		return
+	}
+
+	sendFunc := s.sendFunc
+	s.lck.Unlock()
+
+	for i := 0; ; i++ {
+		err := sendFunc(m)
+		if err == nil {
+			return
+		}
+
+		// the waiting returns at once after closed, never retry without delay
+		if i >= s.cfg.MaxRetry || s.evtStop.IsClose() {
+			s.onMailLost(err, cnt)
+			return
+		}
+
+		s.evtStop.WaitUntilTimeout(s.cfg.RetryInterval)
+		if s.evtStop.IsClose() {
+			s.onMailLost(err, cnt)
+			return
+		}
+	}
+}
+
+// Report the logs which are lost, the logger can't print them.
+// @param err, the error of the last sending.
+// @param cnt, the count of logs.
+func (s *MailLogSink) onMailLost(err error, cnt uint64) {
+	fmt.Fprintf(os.Stderr, "send log mail error: %v, lost %d logs\n", err, cnt)
+}
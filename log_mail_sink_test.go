@@ -0,0 +1,148 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testMailSender struct {
+	lck     sync.Mutex
+	failCnt int
+	mails   []*Mail
+	calls   int
+}
+
+func (s *testMailSender) send(m *Mail) error {
+	s.lck.Lock()
+	defer s.lck.Unlock()
+
+	s.calls++
+	if s.calls <= s.failCnt {
+		return errors.New("test send fail")
+	}
+
+	s.mails = append(s.mails, m)
+	return nil
+}
+
+func (s *testMailSender) getResult() (int, []*Mail) {
+	s.lck.Lock()
+	defer s.lck.Unlock()
+
+	return s.calls, append([]*Mail(nil), s.mails...)
+}
+
+func writeTestMailLogs(t *testing.T, sink *MailLogSink, lines ...string) {
+	logs := make([]*LogInfo, 0, len(lines))
+	for _, line := range lines {
+		logs = append(logs, &LogInfo{LogBuf: []byte(line + "\n")})
+	}
+
+	err := sink.WriteLogs(logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Call f with the stderr redirected.
+// @return string, the output to the stderr.
+func captureTestStderr(t *testing.T, f func()) string {
+	tmp, err := ioutil.TempFile(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer tmp.Close()
+
+	stderr := os.Stderr
+	os.Stderr = tmp
+	f()
+	os.Stderr = stderr
+
+	data, err := ioutil.ReadFile(tmp.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestMailLogSinkDigest(t *testing.T) {
+	sender := &testMailSender{}
+	sink := NewMailLogSink(&LogMailConf{Subject: "alert", MaxLines: 2})
+	sink.SetSendFunc(sender.send)
+	writeTestMailLogs(t, sink, "a < b", "line 2", "line 3")
+	sink.Close()
+
+	calls, mails := sender.getResult()
+	if calls != 1 || len(mails) != 1 {
+		t.Fatalf("calls = %d, mails = %d, want 1 digest at close", calls, len(mails))
+	}
+
+	m := mails[0]
+	if !strings.HasPrefix(m.Subject, "alert - ") || !strings.HasSuffix(m.Subject, " (3 logs)") {
+		t.Errorf("subject = %q", m.Subject)
+	}
+
+	wantBody := "<pre>a &lt; b\nline 2\n... 1 more logs omitted\n</pre>"
+	if m.Body != wantBody {
+		t.Errorf("body = %q, want %q", m.Body, wantBody)
+	}
+}
+
+func TestMailLogSinkRetry(t *testing.T) {
+	sender := &testMailSender{failCnt: 2}
+	sink := NewMailLogSink(&LogMailConf{Window: 1, MaxRetry: 3, RetryInterval: 10})
+	sink.SetSendFunc(sender.send)
+	writeTestMailLogs(t, sink, "retry line")
+
+	// sent by the loop, not at close
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, mails := sender.getResult(); len(mails) > 0 {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sink.Close()
+
+	calls, mails := sender.getResult()
+	if calls != 3 || len(mails) != 1 || !strings.Contains(mails[0].Body, "retry line") {
+		t.Errorf("calls = %d, mails = %d, want sent at the third time", calls, len(mails))
+	}
+}
+
+func TestMailLogSinkLostAtClose(t *testing.T) {
+	sender := &testMailSender{failCnt: 100}
+	sink := NewMailLogSink(&LogMailConf{MaxRetry: 3, RetryInterval: 60000, MaxLines: 1})
+	sink.SetSendFunc(sender.send)
+	writeTestMailLogs(t, sink, "line 1", "line 2")
+
+	start := time.Now()
+	out := captureTestStderr(t, func() {
+		sink.Close()
+	})
+
+	if cost := time.Since(start); cost > 5*time.Second {
+		t.Errorf("Close cost %v, the retries wait after stop", cost)
+	}
+
+	if calls, _ := sender.getResult(); calls != 1 {
+		t.Errorf("calls = %d, want no retry after stop", calls)
+	}
+
+	if !strings.Contains(out, "test send fail") || !strings.Contains(out, "lost 2 logs") {
+		t.Errorf("stderr = %q, want the lost logs reported", out)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+)
+
+//========================
+//      LogCollector
+//========================
+
+// A tiny collector which receives the logs of NetLogSink, for local testing.
+type LogCollector struct {
+	network    string
+	addr       string
+	framing    string
+	handler    func(remote string, log []byte)
+	lckHandler *sync.Mutex
+	lck        *FastLock
+	listener   net.Listener
+	conns      map[net.Conn]bool
+	bStop      bool
+	wg         *sync.WaitGroup
+}
+
+// Create a collector.
+// @param network, tcp or unix.
+// @param addr, the listen address, eg: 127.0.0.1:9514, or the socket path.
+// @param framing, LOG_NET_FRAME_XXX, the same as the sinks.
+// @param handler, it is called with one log each time, nil mean print to stdout. the log is reused after return.
+// @return *LogCollector, the collector.
+func NewLogCollector(network string, addr string, framing string, handler func(remote string, log []byte)) *LogCollector {
+	if len(network) == 0 {
+		network = LOG_NET_DEFAULT_NETWORK
+	}
+
+	if len(framing) == 0 {
+		framing = LOG_NET_FRAME_LINE
+	}
+
+	if handler == nil {
+		handler = printCollectedLog
+	}
+
+	return &LogCollector{
+		network:    network,
+		addr:       addr,
+		framing:    framing,
+		handler:    handler,
+		lckHandler: &sync.Mutex{},
+		lck:        NewFastLock(),
+		listener:   nil,
+		conns:      make(map[net.Conn]bool),
+		bStop:      false,
+		wg:         &sync.WaitGroup{},
+	}
+}
+
+// Start listening.
+// @return error, error.
+func (c *LogCollector) Start() error {
+	if c.framing != LOG_NET_FRAME_LINE && c.framing != LOG_NET_FRAME_LENGTH {
+		return ErrLogNetFramingUnknown
+	}
+
+	// remove the socket of last run
+	if c.network == "unix" {
+		fs, err := os.Stat(c.addr)
+		if err == nil && fs.Mode()&os.ModeSocket != 0 {
+			os.Remove(c.addr)
+		}
+	}
+
+	ln, err := net.Listen(c.network, c.addr)
+	if err != nil {
+		return err
+	}
+
+	c.listener = ln
+	c.wg.Add(1)
+	go c.acceptLoop()
+	return nil
+}
+
+// Get the listen address, eg: the real port when listen to port 0.
+// @return string, the address.
+func (c *LogCollector) Addr() string {
+	if c.listener == nil {
+		return c.addr
+	}
+
+	return c.listener.Addr().String()
+}
+
+// Stop listening and close all the connections.
+func (c *LogCollector) Stop() {
+	if c.listener == nil {
+		return
+	}
+
+	c.listener.Close()
+
+	if c.lck.TryLock(0) != nil {
+		return
+	}
+
+	c.bStop = true
+	for conn := range c.conns {
+		conn.Close()
+	}
+
+	c.lck.Unlock()
+	c.wg.Wait()
+}
+
+func (c *LogCollector) acceptLoop() {
+	defer c.wg.Done()
+
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		if !c.addConn(conn) {
+			conn.Close()
+			continue
+		}
+
+		c.wg.Add(1)
+		go c.readLoop(conn)
+	}
+}
+
+func (c *LogCollector) addConn(conn net.Conn) bool {
+	if c.lck.TryLock(0) != nil {
+		return false
+	}
+
+	defer c.lck.Unlock()
+
+	if c.bStop {
+		return false
+	}
+
+	c.conns[conn] = true
+	return true
+}
+
+func (c *LogCollector) removeConn(conn net.Conn) {
+	if c.lck.TryLock(0) != nil {
+		return
+	}
+
+	defer c.lck.Unlock()
+
+	delete(c.conns, conn)
+}
+
+func (c *LogCollector) readLoop(conn net.Conn) {
+	defer c.wg.Done()
+	defer c.removeConn(conn)
+	defer conn.Close()
+
+	remote := conn.RemoteAddr().String()
+	r := bufio.NewReader(conn)
+	var buf []byte = nil
+	for {
+		var err error = nil
+		if c.framing == LOG_NET_FRAME_LENGTH {
+			buf, err = readLengthFrame(r, buf)
+		} else {
+			buf, err = r.ReadBytes('\n')
+		}
+
+		// the partial frame is dropped
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				fmt.Println("log collector read ", remote, " error: ", err)
+			}
+
+			return
+		}
+
+		c.lckHandler.Lock()
+		c.handler(remote, buf)
+		c.lckHandler.Unlock()
+	}
+}
+
+// Read a frame with 4 bytes big endian length prefix.
+// @param r, the reader.
+// @param buf, the buffer to reuse.
+// @return []byte, the frame.
+// @return error, error.
+func readLengthFrame(r io.Reader, buf []byte) ([]byte, error) {
+	var head [4]byte
+	_, err := io.ReadFull(r, head[:])
+	if err != nil {
+		return buf, err
+	}
+
+	size := binary.BigEndian.Uint32(head[:])
+	if size > LOG_NET_MAX_FRAME_SIZE {
+		return buf, ErrLogNetFrameIsTooLarge
+	}
+
+	if uint32(cap(buf)) < size {
+		buf = make([]byte, size)
+	}
+
+	buf = buf[:size]
+	_, err = io.ReadFull(r, buf)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return buf, err
+}
+
+func printCollectedLog(remote string, log []byte) {
+	os.Stdout.Write(log)
+	if len(log) > 0 && log[len(log)-1] != '\n' {
+		os.Stdout.Write([]byte{'\n'})
+	}
+}
@@ -0,0 +1,573 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	LOG_SINK_TYPE_NET = "net"
+
+	LOG_NET_FRAME_LINE   = "line"   // every log ends with '\n', the line breaks in the log are escaped as "\\n"
+	LOG_NET_FRAME_LENGTH = "length" // every log has a 4 bytes big endian length prefix
+
+	LOG_NET_DEFAULT_NETWORK     = "tcp"
+	LOG_NET_DEFAULT_SPOOL_SIZE  = 64 * 1024 * 1024
+	LOG_NET_DEFAULT_MIN_BACKOFF = 500
+	LOG_NET_DEFAULT_MAX_BACKOFF = 30000
+	LOG_NET_DEFAULT_TIMEOUT     = 5000
+	LOG_NET_SEND_INTV           = 100
+	LOG_NET_MAX_PENDING_SIZE    = 4 * 1024 * 1024
+	LOG_NET_REPLAY_CHUNK_SIZE   = 64 * 1024
+	LOG_NET_MAX_FRAME_SIZE      = 16 * 1024 * 1024
+)
+
+var (
+	ErrLogNetConfIsNil       = errors.New("log net config is nil")
+	ErrLogNetFramingUnknown  = errors.New("unknown log net framing")
+	ErrLogNetSpoolCorrupt    = errors.New("log net spool is corrupt")
+	ErrLogNetFrameIsTooLarge = errors.New("log net frame is too large")
+)
+
+//========================
+//      LogNetConf
+//========================
+type LogNetConf struct {
+	Network      string `json:"network"`        // tcp or unix
+	Addr         string `json:"addr"`           // host:port or the socket path
+	Framing      string `json:"framing"`        // LOG_NET_FRAME_XXX
+	SpoolPath    string `json:"spool_path"`     // keep the logs when disconnected, empty mean drop them
+	MaxSpoolSize int64  `json:"max_spool_size"` // byte, the logs are dropped when the spool is full
+	MinBackoff   uint32 `json:"min_backoff"`    // millisecond, the first reconnect delay
+	MaxBackoff   uint32 `json:"max_backoff"`    // millisecond, the reconnect delay is doubled up to it
+	Timeout      uint32 `json:"timeout"`        // millisecond, dial and write timeout
+}
+
+//========================
+//       NetLogSink
+//========================
+type NetLogSink struct {
+	cfg          LogNetConf
+	lck          *FastLock
+	pending      []byte
+	pendingCnt   uint64
+	sendBuf      []byte
+	replayBuf    []byte
+	conn         net.Conn
+	connClosed   *int32
+	spool        *os.File
+	spoolSize    int64
+	replayOff    int64
+	backoff      uint32
+	nextDialTime time.Time
+	bErrPrinted  bool
+	dropCnt      uint64
+	evtSend      *Event
+	evtStop      *Event
+	evtStopSucc  *Event
+}
+
+// Create a sink which stream the logs to a collector.
+// The logs are spooled to a local file when disconnected, and replayed when reconnected.
+// @param cfg, the net config, the zero values mean the default values.
+// @return *NetLogSink, the sink.
+// @return error, error.
+func NewNetLogSink(cfg *LogNetConf) (*NetLogSink, error) {
+	s := &NetLogSink{
+		cfg:          *cfg,
+		lck:          NewFastLock(),
+		pending:      make([]byte, 0, LOG_NET_REPLAY_CHUNK_SIZE),
+		pendingCnt:   0,
+		sendBuf:      make([]byte, 0, LOG_NET_REPLAY_CHUNK_SIZE),
+		replayBuf:    nil,
+		conn:         nil,
+		connClosed:   nil,
+		spool:        nil,
+		spoolSize:    0,
+		replayOff:    0,
+		backoff:      0,
+		nextDialTime: time.Time{},
+		bErrPrinted:  false,
+		dropCnt:      0,
+		evtSend:      NewEvent(),
+		evtStop:      NewEvent(),
+		evtStopSucc:  NewEvent(),
+	}
+
+	if len(s.cfg.Network) == 0 {
+		s.cfg.Network = LOG_NET_DEFAULT_NETWORK
+	}
+
+	if len(s.cfg.Framing) == 0 {
+		s.cfg.Framing = LOG_NET_FRAME_LINE
+	}
+
+	if s.cfg.Framing != LOG_NET_FRAME_LINE && s.cfg.Framing != LOG_NET_FRAME_LENGTH {
+		return nil, ErrLogNetFramingUnknown
+	}
+
+	if s.cfg.MaxSpoolSize <= 0 {
+		s.cfg.MaxSpoolSize = LOG_NET_DEFAULT_SPOOL_SIZE
+	}
+
+	if s.cfg.MinBackoff == 0 {
+		s.cfg.MinBackoff = LOG_NET_DEFAULT_MIN_BACKOFF
+	}
+
+	if s.cfg.MaxBackoff < s.cfg.MinBackoff {
+		s.cfg.MaxBackoff = LOG_NET_DEFAULT_MAX_BACKOFF
+		if s.cfg.MaxBackoff < s.cfg.MinBackoff {
+			s.cfg.MaxBackoff = s.cfg.MinBackoff
+		}
+	}
+
+	if s.cfg.Timeout == 0 {
+		s.cfg.Timeout = LOG_NET_DEFAULT_TIMEOUT
+	}
+
+	s.backoff = s.cfg.MinBackoff
+
+	// the spool of last run is replayed when connected
+	if len(s.cfg.SpoolPath) > 0 {
+		err := s.openSpool()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	go s.loop()
+	return s, nil
+}
+
+func (s *NetLogSink) WriteLogs(logs []*LogInfo) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	if s.lck.TryLock(0) != nil {
+		return ErrTryLockFail
+	}
+
+	defer s.lck.Unlock()
+
+	for _, info := range logs {
+		// the sender is blocked too long
+		if len(s.pending) >= LOG_NET_MAX_PENDING_SIZE {
+			atomic.AddUint64(&s.dropCnt, 1)
+			continue
+		}
+
+		s.pending = s.appendFrame(s.pending, info.LogBuf)
+		s.pendingCnt++
+	}
+
+	return nil
+}
+
+// Wake up the sender.
+func (s *NetLogSink) Flush() error {
+	s.evtSend.Broadcast()
+	return nil
+}
+
+// Send or spool the rest logs and stop the sink.
+func (s *NetLogSink) Close() error {
+	s.evtStop.Close()
+	s.evtSend.Close()
+	s.evtStopSucc.Wait()
+	return nil
+}
+
+func (s *NetLogSink) appendFrame(buf []byte, log []byte) []byte {
+	if s.cfg.Framing == LOG_NET_FRAME_LENGTH {
+		var head [4]byte
+		binary.BigEndian.PutUint32(head[:], uint32(len(log)))
+		buf = append(buf, head[:]...)
+		return append(buf, log...)
+	}
+
+	// one line for one log, eg: the stacks and the detail logs
+	log = bytes.TrimRight(log, "\r\n")
+	for len(log) > 0 {
+		idx := bytes.IndexAny(log, "\r\n")
+		if idx < 0 {
+			buf = append(buf, log...)
+			break
+		}
+
+		buf = append(buf, log[:idx]...)
+		if log[idx] == '\n' {
+			buf = append(buf, '\\', 'n')
+		} else {
+			buf = append(buf, '\\', 'r')
+		}
+
+		log = log[idx+1:]
+	}
+
+	return append(buf, '\n')
+}
+
+func (s *NetLogSink) loop() {
+	for {
+		bStop := s.evtStop.IsClose()
+		s.sendPending()
+		if bStop {
+			break
+		}
+
+		s.evtSend.WaitUntilTimeout(LOG_NET_SEND_INTV)
+	}
+
+	s.closeConn()
+	if s.spool != nil {
+		s.spool.Close()
+		s.spool = nil
+	}
+
+	s.evtStopSucc.Close()
+}
+
+// Take the pending logs.
+// @return []byte, the frames, it is valid until next taking.
+// @return uint64, the count of logs.
+func (s *NetLogSink) takePending() ([]byte, uint64) {
+	if s.lck.TryLock(0) != nil {
+		return nil, 0
+	}
+
+	defer s.lck.Unlock()
+
+	data, cnt := s.pending, s.pendingCnt
+	s.pending = s.sendBuf[:0]
+	s.pendingCnt = 0
+	s.sendBuf = data
+	return data, cnt
+}
+
+// Send the pending logs, spool them when disconnected.
+func (s *NetLogSink) sendPending() {
+	data, cnt := s.takePending()
+	if !s.connect() {
+		s.spoolFrames(data, cnt)
+		return
+	}
+
+	if len(data) == 0 {
+		return
+	}
+
+	// the logs may be sent twice when fail, the collector keep the whole frames only
+	err := s.write(data)
+	if err != nil {
+		s.onDisconnected(err)
+		s.spoolFrames(data, cnt)
+	}
+}
+
+// Connect to the collector and replay the spool.
+// @return bool, true mean connected.
+func (s *NetLogSink) connect() bool {
+	if s.conn != nil && atomic.LoadInt32(s.connClosed) == 1 {
+		s.onDisconnected(io.EOF)
+	}
+
+	if s.conn != nil {
+		return true
+	}
+
+	if time.Now().Before(s.nextDialTime) {
+		return false
+	}
+
+	conn, err := net.DialTimeout(s.cfg.Network, s.cfg.Addr, time.Duration(s.cfg.Timeout)*time.Millisecond)
+	if err != nil {
+		s.onDisconnected(err)
+		return false
+	}
+
+	s.conn = conn
+	s.connClosed = new(int32)
+	go watchLogNetConn(conn, s.connClosed)
+
+	err = s.replaySpool()
+	if err != nil {
+		s.onDisconnected(err)
+		return false
+	}
+
+	s.backoff = s.cfg.MinBackoff
+	if s.bErrPrinted {
+		s.bErrPrinted = false
+		fmt.Println("log sink connected to ", s.cfg.Addr)
+	}
+
+	dropCnt := atomic.SwapUint64(&s.dropCnt, 0)
+	if dropCnt > 0 {
+		fmt.Println("log sink ", s.cfg.Addr, " dropped ", dropCnt, " logs")
+	}
+
+	return true
+}
+
+// Close the connection and delay the next dialing.
+// @param err, the error.
+func (s *NetLogSink) onDisconnected(err error) {
+	s.closeConn()
+	if !s.bErrPrinted {
+		s.bErrPrinted = true
+		fmt.Println("log sink connect to ", s.cfg.Addr, " error: ", err)
+	}
+
+	s.nextDialTime = time.Now().Add(time.Duration(s.backoff) * time.Millisecond)
+	s.backoff *= 2
+	if s.backoff > s.cfg.MaxBackoff {
+		s.backoff = s.cfg.MaxBackoff
+	}
+}
+
+func (s *NetLogSink) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// Detect the connection closed by the collector, the collector never send data.
+// @param conn, the connection.
+// @param bClosed, set to 1 when closed.
+func watchLogNetConn(conn net.Conn, bClosed *int32) {
+	var buf [64]byte
+	for {
+		_, err := conn.Read(buf[:])
+		if err != nil {
+			atomic.StoreInt32(bClosed, 1)
+			return
+		}
+	}
+}
+
+func (s *NetLogSink) write(data []byte) error {
+	s.conn.SetWriteDeadline(time.Now().Add(time.Duration(s.cfg.Timeout) * time.Millisecond))
+	_, err := s.conn.Write(data)
+	return err
+}
+
+//========================
+//   NetLogSink spool
+//========================
+func (s *NetLogSink) openSpool() error {
+	f, err := os.OpenFile(s.cfg.SpoolPath, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+
+	fs, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	// a frame may be cut by a crash, never append the new frames after it
+	size, err := s.getSpoolFramesSize(f, fs.Size())
+	if err == nil && size < fs.Size() {
+		err = f.Truncate(size)
+	}
+
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	s.spool = f
+	s.spoolSize = size
+	s.replayOff = 0
+	return nil
+}
+
+// Get the size of the whole frames in the spool.
+// @param f, the spool.
+// @param fileSize, the size of the spool.
+// @return int64, the size.
+// @return error, error.
+func (s *NetLogSink) getSpoolFramesSize(f *os.File, fileSize int64) (int64, error) {
+	if s.cfg.Framing == LOG_NET_FRAME_LENGTH {
+		r := bufio.NewReaderSize(io.NewSectionReader(f, 0, fileSize), LOG_NET_REPLAY_CHUNK_SIZE)
+		size := int64(0)
+		var head [4]byte
+		for {
+			_, err := io.ReadFull(r, head[:])
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return size, nil
+			} else if err != nil {
+				return 0, err
+			}
+
+			frameSize := int64(binary.BigEndian.Uint32(head[:]))
+			if frameSize > LOG_NET_MAX_FRAME_SIZE || size+4+frameSize > fileSize {
+				return size, nil
+			}
+
+			_, err = r.Discard(int(frameSize))
+			if err != nil {
+				return 0, err
+			}
+
+			size += 4 + frameSize
+		}
+	}
+
+	// the end of the last line
+	buf := make([]byte, LOG_NET_REPLAY_CHUNK_SIZE)
+	end := fileSize
+	for end > 0 {
+		start := end - int64(len(buf))
+		if start < 0 {
+			start = 0
+		}
+
+		n, err := f.ReadAt(buf[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		idx := bytes.LastIndexByte(buf[:n], '\n')
+		if idx >= 0 {
+			return start + int64(idx) + 1, nil
+		}
+
+		end = start
+	}
+
+	return 0, nil
+}
+
+// Append the frames to the spool, drop them when no spool or the spool is full.
+// @param data, the frames.
+// @param cnt, the count of logs.
+func (s *NetLogSink) spoolFrames(data []byte, cnt uint64) {
+	if len(data) == 0 {
+		return
+	}
+
+	if s.spool == nil || s.spoolSize+int64(len(data)) > s.cfg.MaxSpoolSize {
+		atomic.AddUint64(&s.dropCnt, cnt)
+		return
+	}
+
+	// one writing, the partial frames are removed when fail
+	n, err := s.spool.Write(data)
+	if err != nil {
+		fmt.Println("write log spool ", s.cfg.SpoolPath, " error: ", err)
+		atomic.AddUint64(&s.dropCnt, cnt)
+		if n > 0 {
+			err = s.spool.Truncate(s.spoolSize)
+			if err != nil {
+				fmt.Println("truncate log spool ", s.cfg.SpoolPath, " error: ", err)
+				s.spoolSize += int64(n)
+			}
+		}
+
+		return
+	}
+
+	s.spoolSize += int64(n)
+}
+
+// Send the spooled frames in order, the spool is cleared when all are sent.
+// @return error, error.
+func (s *NetLogSink) replaySpool() error {
+	if s.spool == nil || s.spoolSize == 0 {
+		return nil
+	}
+
+	if s.replayBuf == nil {
+		s.replayBuf = make([]byte, LOG_NET_REPLAY_CHUNK_SIZE)
+	}
+
+	for s.replayOff < s.spoolSize {
+		readSize := int64(len(s.replayBuf))
+		if readSize > s.spoolSize-s.replayOff {
+			readSize = s.spoolSize - s.replayOff
+		}
+
+		n, err := s.spool.ReadAt(s.replayBuf[:readSize], s.replayOff)
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		chunk := s.replayBuf[:n]
+		size, need := s.getFramesSize(chunk)
+		if size == 0 {
+			// the incomplete frame at the end or the broken rest of the spool, drop it
+			if int64(n) < readSize || s.replayOff+int64(n) >= s.spoolSize || need > LOG_NET_MAX_FRAME_SIZE {
+				fmt.Println("replay log spool ", s.cfg.SpoolPath, " error: ", ErrLogNetSpoolCorrupt)
+				break
+			}
+
+			// one frame is larger than the buffer
+			s.replayBuf = make([]byte, need)
+			continue
+		}
+
+		err = s.write(chunk[:size])
+		if err != nil {
+			return err
+		}
+
+		s.replayOff += int64(size)
+	}
+
+	err := s.spool.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	s.spoolSize = 0
+	s.replayOff = 0
+	return nil
+}
+
+// Get the size of the whole frames at the head of the data.
+// @param data, the data.
+// @return int, the size, 0 mean no whole frame.
+// @return int, the size of the first frame when no whole frame.
+func (s *NetLogSink) getFramesSize(data []byte) (int, int) {
+	if s.cfg.Framing == LOG_NET_FRAME_LENGTH {
+		size := 0
+		for size+4 <= len(data) {
+			frameSize := 4 + int(binary.BigEndian.Uint32(data[size:]))
+			if size+frameSize > len(data) {
+				if size == 0 {
+					return 0, frameSize
+				}
+
+				break
+			}
+
+			size += frameSize
+		}
+
+		return size, 0
+	}
+
+	for i := len(data) - 1; i >= 0; i-- {
+		if data[i] == '\n' {
+			return i + 1, 0
+		}
+	}
+
+	return 0, len(data) * 2
+}
@@ -0,0 +1,214 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+const TEST_NET_WAIT_TIMEOUT = 5 * time.Second
+
+// The logs received by a collector.
+type testCollectedLogs struct {
+	lck  *sync.Mutex
+	logs []string
+}
+
+func newTestCollectedLogs() *testCollectedLogs {
+	return &testCollectedLogs{
+		lck:  &sync.Mutex{},
+		logs: make([]string, 0),
+	}
+}
+
+func (c *testCollectedLogs) handle(remote string, log []byte) {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+
+	c.logs = append(c.logs, string(log))
+}
+
+func (c *testCollectedLogs) get() []string {
+	c.lck.Lock()
+	defer c.lck.Unlock()
+
+	logs := make([]string, len(c.logs))
+	copy(logs, c.logs)
+	return logs
+}
+
+// Wait until the collector receives cnt logs.
+func (c *testCollectedLogs) wait(t *testing.T, cnt int) []string {
+	deadline := time.Now().Add(TEST_NET_WAIT_TIMEOUT)
+	for {
+		logs := c.get()
+		if len(logs) >= cnt {
+			return logs
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("collector received %v, want %d logs", logs, cnt)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func startTestCollector(t *testing.T, network string, addr string, framing string, logs *testCollectedLogs) *LogCollector {
+	c := NewLogCollector(network, addr, framing, logs.handle)
+	err := c.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func writeTestNetLogs(t *testing.T, s *NetLogSink, strs ...string) {
+	logs := make([]*LogInfo, 0, len(strs))
+	for _, str := range strs {
+		logs = append(logs, &LogInfo{LogBuf: []byte(str)})
+	}
+
+	err := s.WriteLogs(logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Flush()
+}
+
+func TestNetLogSinkFraming(t *testing.T) {
+	cases := []struct {
+		network string
+		framing string
+		want    []string
+	}{
+		{"tcp", LOG_NET_FRAME_LINE, []string{"one\n", "two\\nthree\\r\\nfour\n"}},
+		{"unix", LOG_NET_FRAME_LINE, []string{"one\n", "two\\nthree\\r\\nfour\n"}},
+		{"tcp", LOG_NET_FRAME_LENGTH, []string{"one\n", "two\nthree\r\nfour\n"}},
+		{"unix", LOG_NET_FRAME_LENGTH, []string{"one\n", "two\nthree\r\nfour\n"}},
+	}
+
+	for _, c := range cases {
+		addr := "127.0.0.1:0"
+		if c.network == "unix" {
+			addr = filepath.Join(t.TempDir(), "collector.sock")
+		}
+
+		logs := newTestCollectedLogs()
+		collector := startTestCollector(t, c.network, addr, c.framing, logs)
+		sink, err := NewNetLogSink(&LogNetConf{Network: c.network, Addr: collector.Addr(), Framing: c.framing})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		writeTestNetLogs(t, sink, "one\n", "two\nthree\r\nfour\n")
+		got := logs.wait(t, len(c.want))
+		sink.Close()
+		collector.Stop()
+
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s %s: collector received %q, want %q", c.network, c.framing, got, c.want)
+		}
+	}
+}
+
+func TestNetLogSinkSpoolReplay(t *testing.T) {
+	dir := t.TempDir()
+	addr := filepath.Join(dir, "collector.sock")
+	logs := newTestCollectedLogs()
+	collector := startTestCollector(t, "unix", addr, LOG_NET_FRAME_LINE, logs)
+
+	sink, err := NewNetLogSink(&LogNetConf{
+		Network:    "unix",
+		Addr:       addr,
+		SpoolPath:  filepath.Join(dir, "spool"),
+		MinBackoff: 10,
+		MaxBackoff: 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer sink.Close()
+
+	writeTestNetLogs(t, sink, "connected\n")
+	logs.wait(t, 1)
+
+	// the sink spools the logs until the collector restarts
+	collector.Stop()
+	writeTestNetLogs(t, sink, "spooled 1\n", "spooled 2\n")
+	time.Sleep(50 * time.Millisecond)
+	writeTestNetLogs(t, sink, "spooled 3\n")
+	time.Sleep(50 * time.Millisecond)
+	if got := logs.get(); len(got) != 1 {
+		t.Fatalf("collector received %q while stopped", got)
+	}
+
+	collector = startTestCollector(t, "unix", addr, LOG_NET_FRAME_LINE, logs)
+	defer collector.Stop()
+
+	writeTestNetLogs(t, sink, "reconnected\n")
+	got := logs.wait(t, 5)
+	want := []string{"connected\n", "spooled 1\n", "spooled 2\n", "spooled 3\n", "reconnected\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collector received %q, want %q", got, want)
+	}
+}
+
+func TestNetLogSinkSpoolIncompleteFrame(t *testing.T) {
+	lengthSpool := make([]byte, 0)
+	for _, frame := range []string{"one\n", "two\n"} {
+		var head [4]byte
+		binary.BigEndian.PutUint32(head[:], uint32(len(frame)))
+		lengthSpool = append(lengthSpool, head[:]...)
+		lengthSpool = append(lengthSpool, frame...)
+	}
+
+	// a frame cut by a crash
+	lengthSpool = append(lengthSpool, 0, 0, 0, 8, 'p', 'a', 'r')
+
+	cases := []struct {
+		framing string
+		spool   []byte
+	}{
+		{LOG_NET_FRAME_LINE, []byte("one\ntwo\npart")},
+		{LOG_NET_FRAME_LENGTH, lengthSpool},
+	}
+
+	for _, c := range cases {
+		dir := t.TempDir()
+		spoolPath := filepath.Join(dir, "spool")
+		err := ioutil.WriteFile(spoolPath, c.spool, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		addr := filepath.Join(dir, "collector.sock")
+		logs := newTestCollectedLogs()
+		collector := startTestCollector(t, "unix", addr, c.framing, logs)
+		sink, err := NewNetLogSink(&LogNetConf{Network: "unix", Addr: addr, Framing: c.framing, SpoolPath: spoolPath})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		writeTestNetLogs(t, sink, "three\n")
+		got := logs.wait(t, 3)
+		sink.Close()
+		collector.Stop()
+
+		want := []string{"one\n", "two\n", "three\n"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: collector received %q, want %q", c.framing, got, want)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const LOG_POOL_MAX_BUFF_SIZE = 4 * 1024
+
+var logInfoFactory = NewObjectFactory()
+var logInfoObjName, _ = logInfoFactory.RegisterObject(&LogInfo{}, newPoolLogInfo, 0)
+var logBuffFactory = NewBuffFactory(LOG_STR_BUILD_INIT_CAP, LOG_POOL_MAX_BUFF_SIZE, LOG_STR_BUILD_INIT_CAP)
+
+//========================
+//      LogInfo pool
+//========================
+func newPoolLogInfo() interface{} {
+	return &LogInfo{}
+}
+
+func createLogInfo() *LogInfo {
+	obj, err := logInfoFactory.CreateObject(logInfoObjName)
+	if err != nil {
+		return &LogInfo{}
+	}
+
+	return obj.(*LogInfo)
+}
+
+// Give the log back to the pool, the log can't be used any more.
+// @param info, the log.
+func releaseLogInfo(info *LogInfo) {
+	if info.buffRef != nil {
+		logBuffFactory.ReuseBuff(info.buffRef)
+		info.buffRef = nil
+	}
+
+	// release the references
+	for i := range info.argBuf {
+		info.argBuf[i] = nil
+	}
+
+	info.argBuf = info.argBuf[:0]
+	info.Tag = ""
+	info.Caller = ""
+	info.Func = ""
+	info.Format = ""
+	info.Args = nil
+	info.Fields = nil
+	info.Stack = ""
+	info.LogBuf = nil
+	info.flushEvt = nil
+	logInfoFactory.ReuseObject(info, logInfoObjName)
+}
+
+// Give the logs back to the pool and clear the slice.
+// @param logs, the logs.
+func releaseLogInfos(logs []*LogInfo) {
+	for i, info := range logs {
+		releaseLogInfo(info)
+		logs[i] = nil
+	}
+}
+
+// Get a formatting buffer from the pool for the log.
+// @param info, the log.
+// @return []byte, an empty buffer.
+func getLogInfoBuff(info *LogInfo) []byte {
+	info.buffRef = logBuffFactory.CreateBuff(uint32(LOG_STR_BUILD_INIT_CAP + len(info.Stack)))
+	return (*info.buffRef)[:0]
+}
+
+//========================
+//       logBuffer
+//========================
+
+// A byte buffer which can be printed to by fmt.Fprint.
+type logBuffer struct {
+	b []byte
+}
+
+func newLogBuffer() *logBuffer {
+	return &logBuffer{
+		b: make([]byte, 0, LOG_STR_BUILD_INIT_CAP),
+	}
+}
+
+func (w *logBuffer) Write(p []byte) (int, error) {
+	w.b = append(w.b, p...)
+	return len(p), nil
+}
+
+//========================
+//     append helper
+//========================
+
+// Append an unsigned integer.
+// @param buf, the buffer.
+// @param num, the number.
+// @param width, the min width, fill zero to prefix.
+// @return []byte, the buffer.
+func appendLogUint(buf []byte, num uint64, width int) []byte {
+	digits := 1
+	for n := num; n >= 10; n /= 10 {
+		digits++
+	}
+
+	for ; digits < width; digits++ {
+		buf = append(buf, '0')
+	}
+
+	return strconv.AppendUint(buf, num, 10)
+}
+
+// Append a json string, same as json.Marshal.
+// @param buf, the buffer.
+// @param s, the string.
+// @return []byte, the buffer.
+func appendJsonString(buf []byte, s string) []byte {
+	if !isJsonSafeString(s) {
+		data, _ := json.Marshal(s)
+		return append(buf, data...)
+	}
+
+	buf = append(buf, '"')
+	buf = append(buf, s...)
+	return append(buf, '"')
+}
+
+// Append a json string of the bytes, same as json.Marshal.
+// @param buf, the buffer.
+// @param b, the bytes.
+// @return []byte, the buffer.
+func appendJsonBytes(buf []byte, b []byte) []byte {
+	if !isJsonSafeBytes(b) {
+		data, _ := json.Marshal(string(b))
+		return append(buf, data...)
+	}
+
+	buf = append(buf, '"')
+	buf = append(buf, b...)
+	return append(buf, '"')
+}
+
+// Append a json value.
+// @param buf, the buffer.
+// @param v, the value.
+// @return []byte, the buffer.
+func appendJsonValue(buf []byte, v interface{}) []byte {
+	switch val := v.(type) {
+	case string:
+		return appendJsonString(buf, val)
+	case error:
+		return appendJsonString(buf, val.Error())
+	case bool:
+		return strconv.AppendBool(buf, val)
+	case int:
+		return strconv.AppendInt(buf, int64(val), 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(val), 10)
+	case int64:
+		return strconv.AppendInt(buf, val, 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(val), 10)
+	case uint32:
+		return strconv.AppendUint(buf, uint64(val), 10)
+	case uint64:
+		return strconv.AppendUint(buf, val, 10)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+
+	return append(buf, data...)
+}
+
+// Check if the string can be written to json without escaping.
+// @param s, the string.
+// @return bool, true mean no need to escape.
+func isJsonSafeString(s string) bool {
+	bMultiByte := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			bMultiByte = true
+		} else if !isJsonSafeByte(c) {
+			return false
+		}
+	}
+
+	if !bMultiByte {
+		return true
+	}
+
+	return utf8.ValidString(s) && !strings.Contains(s, "\u2028") && !strings.Contains(s, "\u2029")
+}
+
+// Check if the bytes can be written to json without escaping.
+// @param b, the bytes.
+// @return bool, true mean no need to escape.
+func isJsonSafeBytes(b []byte) bool {
+	bMultiByte := false
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			bMultiByte = true
+		} else if !isJsonSafeByte(c) {
+			return false
+		}
+	}
+
+	if !bMultiByte {
+		return true
+	}
+
+	return utf8.Valid(b) && !bytes.Contains(b, []byte("\u2028")) && !bytes.Contains(b, []byte("\u2029"))
+}
+
+func isJsonSafeByte(c byte) bool {
+	// json.Marshal escapes the html characters
+	return c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&'
+}
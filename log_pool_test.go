@@ -0,0 +1,112 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"io"
+	"testing"
+)
+
+func newBenchLogger(lv LogLv) *IndependentLogger {
+	l := NewIndependentLogger("bench")
+	l.SetLogLevel(lv)
+	l.RemoveLogSink(LOG_SINK_NAME_CONSOLE)
+	l.AddLogSink("discard", NewWriterLogSink(io.Discard), LOG_LV_TRACE)
+	l.StartLogger()
+	return l
+}
+
+func runLogBenchmark(b *testing.B, l *IndependentLogger, printLog func(l *IndependentLogger)) {
+	defer l.StopLogger()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		printLog(l)
+	}
+
+	// the writing of the queued logs is not measured
+	b.StopTimer()
+	l.FlushLogger()
+}
+
+// The average allocations of a log, include the writing in the writer goroutine.
+func getLogAllocs(l *IndependentLogger, printLog func(l *IndependentLogger)) float64 {
+	const cnt = 1000
+	allocs := testing.AllocsPerRun(5, func() {
+		for i := 0; i < cnt; i++ {
+			printLog(l)
+		}
+
+		l.FlushLogger()
+	})
+
+	return allocs / cnt
+}
+
+func TestLogAllocs(t *testing.T) {
+	if testRaceEnabled {
+		t.Skip("the pools are not reliable with the race detector")
+	}
+
+	l := newBenchLogger(LOG_LV_INFO)
+	defer l.StopLogger()
+
+	child := l.With("service", "bench", "node", 1)
+	cases := []struct {
+		name     string
+		bJson    bool
+		printLog func(l *IndependentLogger)
+		budget   float64
+	}{
+		{"filtered", false, func(l *IndependentLogger) { l.D("filtered line", 1) }, 0.1},
+		{"plain", false, func(l *IndependentLogger) { l.I("plain line", 1) }, 1},
+		{"fields", false, func(l *IndependentLogger) { child.I("fields line", LogKV("user", "bob"), LogKV("cost", 12)) }, 3},
+		{"fields json", true, func(l *IndependentLogger) { child.I("fields line", LogKV("user", "bob"), LogKV("cost", 12)) }, 4},
+	}
+
+	for _, c := range cases {
+		l.SetJsonFormat(c.bJson)
+		allocs := getLogAllocs(l, c.printLog)
+		if allocs > c.budget {
+			t.Errorf("%s: %.2f allocs per log, budget %.2f", c.name, allocs, c.budget)
+		}
+	}
+}
+
+func BenchmarkLogFiltered(b *testing.B) {
+	l := newBenchLogger(LOG_LV_INFO)
+	runLogBenchmark(b, l, func(l *IndependentLogger) {
+		l.D("filtered line", 1)
+	})
+}
+
+func BenchmarkLogPlain(b *testing.B) {
+	l := newBenchLogger(LOG_LV_INFO)
+	runLogBenchmark(b, l, func(l *IndependentLogger) {
+		l.I("plain line", 1)
+	})
+}
+
+func BenchmarkLogFieldsJson(b *testing.B) {
+	l := newBenchLogger(LOG_LV_INFO)
+	l.SetJsonFormat(true)
+	child := l.With("service", "bench", "node", 1)
+	runLogBenchmark(b, child, func(l *IndependentLogger) {
+		l.I("fields line", LogKV("user", "bob"), LogKV("cost", 12))
+	})
+}
+
+func BenchmarkLogDetail(b *testing.B) {
+	l := newBenchLogger(LOG_LV_INFO)
+	logs := [][]interface{}{
+		{"detail line", 1},
+		{"detail line", 2},
+	}
+
+	runLogBenchmark(b, l, func(l *IndependentLogger) {
+		l.Detail(LOG_LV_INFO, logs)
+	})
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	LOG_OVERFLOW_BLOCK            = "block"
+	LOG_OVERFLOW_DROP_NEWEST      = "drop_newest"
+	LOG_OVERFLOW_DROP_OLDEST      = "drop_oldest"
+	LOG_OVERFLOW_DROP_BELOW_LEVEL = "drop_below_level"
+
+	LOG_DEFAULT_DROP_REPORT_INTV = 10000
+	LOG_DROP_REPORT_TAG          = "logger"
+)
+
+//========================
+//      logQueueConf
+//========================
+type logQueueConf struct {
+	capacity       int // 0 mean no limit
+	policy         string
+	overflowLv     LogLv
+	reportIntvMs   uint32
+	lastReportTime time.Time
+	lastDropCnt    uint64
+}
+
+func newLogQueueConf() *logQueueConf {
+	return &logQueueConf{
+		capacity:       0,
+		policy:         LOG_OVERFLOW_DROP_NEWEST,
+		overflowLv:     LOG_LV_WARN,
+		reportIntvMs:   LOG_DEFAULT_DROP_REPORT_INTV,
+		lastReportTime: time.Now(),
+		lastDropCnt:    0,
+	}
+}
+
+//========================
+//     logger queue
+//========================
+
+// Set the capacity and the overflow policy of the log queue.
+// @param capacity, the max count of logs in queue, 0 mean no limit.
+// @param policy, LOG_OVERFLOW_XXX.
+// @param overflowLv, logs below the level will be dropped when the policy is LOG_OVERFLOW_DROP_BELOW_LEVEL.
+func (l *logger) setQueueConf(capacity int, policy string, overflowLv LogLv) {
+	if len(policy) == 0 {
+		policy = LOG_OVERFLOW_DROP_NEWEST
+	}
+
+	// l.lck.Lock()
+	if l.lck.TryLock(0) != nil {
+		return
+	}
+
+	defer l.lck.Unlock()
+
+	l.queConf.capacity = capacity
+	l.queConf.policy = policy
+	l.queConf.overflowLv = overflowLv
+}
+
+// Set the interval to report the dropped logs.
+// @param intervalMs, the interval in millisecond, 0 mean the default interval.
+func (l *logger) setDropReportInterval(intervalMs uint32) {
+	if intervalMs == 0 {
+		intervalMs = LOG_DEFAULT_DROP_REPORT_INTV
+	}
+
+	// l.lck.Lock()
+	if l.lck.TryLock(0) != nil {
+		return
+	}
+
+	defer l.lck.Unlock()
+
+	l.queConf.reportIntvMs = intervalMs
+}
+
+// Get the count of dropped logs.
+// @return uint64, the count.
+func (l *logger) getDropCount() uint64 {
+	return atomic.LoadUint64(&l.dropCnt)
+}
+
+// Push a log with the overflow policy, must be called in lock.
+// @param info, the log.
+// @param bCanBlock, true mean the caller can wait when the policy is LOG_OVERFLOW_BLOCK.
+// @return bool, false mean the queue is full and the caller need to wait.
+func (l *logger) tryPushOneLog(info *LogInfo, bCanBlock bool) bool {
+	capacity := l.queConf.capacity
+	if capacity <= 0 || len(l.queLogs)-l.queHead < capacity {
+		l.pushOneLog(info)
+		return true
+	}
+
+	switch l.queConf.policy {
+	case LOG_OVERFLOW_BLOCK:
+		if bCanBlock {
+			return false
+		}
+
+		atomic.AddUint64(&l.dropCnt, 1)
+		releaseLogInfo(info)
+
+	case LOG_OVERFLOW_DROP_OLDEST:
+		// keep the flush mark
+		if l.queLogs[l.queHead].flushEvt != nil {
+			atomic.AddUint64(&l.dropCnt, 1)
+			releaseLogInfo(info)
+			break
+		}
+
+		releaseLogInfo(l.queLogs[l.queHead])
+		l.queLogs[l.queHead] = nil
+		l.queHead++
+		if l.queHead >= capacity {
+			l.compactQueue()
+		}
+
+		atomic.AddUint64(&l.dropCnt, 1)
+		l.pushOneLog(info)
+
+	case LOG_OVERFLOW_DROP_BELOW_LEVEL:
+		if info.Lv < l.queConf.overflowLv {
+			atomic.AddUint64(&l.dropCnt, 1)
+			releaseLogInfo(info)
+		} else {
+			l.pushOneLog(info)
+		}
+
+	default:
+		atomic.AddUint64(&l.dropCnt, 1)
+		releaseLogInfo(info)
+	}
+
+	return true
+}
+
+// Wait the writer to pop the logs.
+// @return bool, false mean no writer pops the logs, eg: the logger is not started or has stopped.
+func (l *logger) waitQueueNotFull() bool {
+	if l.isStop() || !l.isRunning() {
+		return false
+	}
+
+	l.evtDumpToFile.Broadcast()
+	l.evtQueNotFull.WaitUntilTimeout(LOG_DEFAULT_DUMP_INTV)
+	return true
+}
+
+// Remove the dropped logs in the head of queue, must be called in lock.
+func (l *logger) compactQueue() {
+	if l.queHead == 0 {
+		return
+	}
+
+	cnt := copy(l.queLogs, l.queLogs[l.queHead:])
+	for i := cnt; i < len(l.queLogs); i++ {
+		l.queLogs[i] = nil
+	}
+
+	l.queLogs = l.queLogs[:cnt]
+	l.queHead = 0
+}
+
+// Append a warning log to the write logs if some logs were dropped in the report interval.
+func (l *logger) appendDropReport() {
+	// the settings are changed in lock, the report fields are only used by the writer
+	// l.lck.Lock()
+	if l.lck.TryLock(0) != nil {
+		return
+	}
+
+	capacity := l.queConf.capacity
+	policy := l.queConf.policy
+	reportIntvMs := l.queConf.reportIntvMs
+	l.lck.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.queConf.lastReportTime) < time.Duration(reportIntvMs)*time.Millisecond {
+		return
+	}
+
+	dropCnt := l.getDropCount()
+	intvDropCnt := dropCnt - l.queConf.lastDropCnt
+	intv := now.Sub(l.queConf.lastReportTime)
+	l.queConf.lastReportTime = now
+	l.queConf.lastDropCnt = dropCnt
+	if intvDropCnt == 0 {
+		return
+	}
+
+	info := newLogInfo(LOG_LV_WARN, LOG_DROP_REPORT_TAG, nil, LogArgs(
+		"dropped ", strconv.FormatUint(intvDropCnt, 10), " logs in last ", intv.Round(time.Second).String(),
+		", total ", strconv.FormatUint(dropCnt, 10), ", queue capacity ", strconv.Itoa(capacity),
+		", policy ", policy), false)
+	l.writeLogs = append(l.writeLogs, info)
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+const TEST_LOG_FLUSH_MARK = "flush"
+
+func newQueueTestLogger(capacity int, policy string) (*IndependentLogger, *LogCapture) {
+	l := NewIndependentLogger("queue")
+	c := NewLogCapture()
+	l.RemoveLogSink(LOG_SINK_NAME_CONSOLE)
+	l.AddLogSink("capture", c, LOG_LV_TRACE)
+	l.SetLogQueue(capacity, policy, LOG_LV_WARN)
+	return l, c
+}
+
+func getQueueTags(l *logger) []string {
+	tags := make([]string, 0)
+	for _, info := range l.queLogs[l.queHead:] {
+		if info.flushEvt != nil {
+			tags = append(tags, TEST_LOG_FLUSH_MARK)
+		} else {
+			tags = append(tags, info.Tag)
+		}
+	}
+
+	return tags
+}
+
+func TestTryPushOneLog(t *testing.T) {
+	cases := []struct {
+		name      string
+		policy    string
+		queued    []string // TEST_LOG_FLUSH_MARK mean a flush mark
+		lv        LogLv
+		bCanBlock bool
+		wantSucc  bool
+		wantTags  []string
+		wantDrop  uint64
+	}{
+		{"not full", LOG_OVERFLOW_DROP_NEWEST, []string{"a"}, LOG_LV_INFO, false, true, []string{"a", "new"}, 0},
+		{"drop newest", LOG_OVERFLOW_DROP_NEWEST, []string{"a", "b"}, LOG_LV_ERROR, false, true, []string{"a", "b"}, 1},
+		{"drop oldest", LOG_OVERFLOW_DROP_OLDEST, []string{"a", "b"}, LOG_LV_INFO, false, true, []string{"b", "new"}, 1},
+		{"drop oldest keep flush mark", LOG_OVERFLOW_DROP_OLDEST, []string{TEST_LOG_FLUSH_MARK, "b"}, LOG_LV_INFO, false, true, []string{TEST_LOG_FLUSH_MARK, "b"}, 1},
+		{"drop below level", LOG_OVERFLOW_DROP_BELOW_LEVEL, []string{"a", "b"}, LOG_LV_INFO, false, true, []string{"a", "b"}, 1},
+		{"keep level", LOG_OVERFLOW_DROP_BELOW_LEVEL, []string{"a", "b"}, LOG_LV_WARN, false, true, []string{"a", "b", "new"}, 0},
+		{"block", LOG_OVERFLOW_BLOCK, []string{"a", "b"}, LOG_LV_INFO, true, false, []string{"a", "b"}, 0},
+		{"block can't wait", LOG_OVERFLOW_BLOCK, []string{"a", "b"}, LOG_LV_INFO, false, true, []string{"a", "b"}, 1},
+	}
+
+	for _, c := range cases {
+		l := newLoggerImpl()
+		l.setQueueConf(2, c.policy, LOG_LV_WARN)
+		for _, tag := range c.queued {
+			info := newLogInfo(LOG_LV_INFO, tag, nil, nil, false)
+			if tag == TEST_LOG_FLUSH_MARK {
+				info.flushEvt = NewEvent()
+			}
+
+			l.pushOneLog(info)
+		}
+
+		bSucc := l.tryPushOneLog(newLogInfo(c.lv, "new", nil, nil, false), c.bCanBlock)
+		if bSucc != c.wantSucc {
+			t.Errorf("%s: tryPushOneLog() = %v, want %v", c.name, bSucc, c.wantSucc)
+		}
+
+		if tags := getQueueTags(l); !reflect.DeepEqual(tags, c.wantTags) {
+			t.Errorf("%s: queue = %v, want %v", c.name, tags, c.wantTags)
+		}
+
+		if cnt := l.getDropCount(); cnt != c.wantDrop {
+			t.Errorf("%s: drop count = %d, want %d", c.name, cnt, c.wantDrop)
+		}
+	}
+}
+
+func TestLogQueueBlockBeforeStart(t *testing.T) {
+	l, c := newQueueTestLogger(1, LOG_OVERFLOW_BLOCK)
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 3; i++ {
+			l.I("before start", i)
+		}
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("log on a full queue before start is blocked")
+	}
+
+	if cnt := l.GetLogDropCount(); cnt != 2 {
+		t.Errorf("GetLogDropCount() = %d, want 2", cnt)
+	}
+
+	l.StartLogger()
+	defer l.StopLogger()
+
+	l.FlushLogger()
+	if n := c.Count(LOG_LV_TRACE, "queue", "before start"); n != 1 {
+		t.Errorf("Count(before start) = %d, want 1", n)
+	}
+}
+
+func TestLogDropReport(t *testing.T) {
+	l, c := newQueueTestLogger(1, LOG_OVERFLOW_DROP_NEWEST)
+	l.loggerImpl.setDropReportInterval(1)
+	l.I("kept")
+	l.I("dropped")
+	l.I("dropped")
+	time.Sleep(2 * time.Millisecond)
+
+	l.StartLogger()
+	defer l.StopLogger()
+
+	l.FlushLogger()
+	if !c.Contains(LOG_LV_WARN, LOG_DROP_REPORT_TAG, "dropped 2 logs") {
+		t.Errorf("drop report not found, logs = %v", c.Logs())
+	}
+
+	if !c.Contains(LOG_LV_WARN, LOG_DROP_REPORT_TAG, "queue capacity 1, policy "+LOG_OVERFLOW_DROP_NEWEST) {
+		t.Errorf("drop report without the queue settings, logs = %v", c.Logs())
+	}
+
+	if n := c.Count(LOG_LV_TRACE, "queue", "dropped"); n != 0 {
+		t.Errorf("Count(dropped) = %d, want 0", n)
+	}
+}
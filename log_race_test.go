@@ -0,0 +1,11 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build race
+// +build race
+
+package yx
+
+// the race detector drops the pooled objects randomly
+const testRaceEnabled = true
@@ -0,0 +1,639 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	LOG_DEFAULT_REDACT_MASK = "******"
+	LOG_REDACT_MAX_DEPTH    = 8
+)
+
+//========================
+//    global method
+//========================
+
+// Set the redaction rules, the secrets are masked in the writer goroutine before any sink see the logs.
+// @param fields, the field names to mask, case insensitive, '*' match any sequence of characters, eg: "password", "*token*".
+//        it is matched with the keys of LogField and map, the exported fields of struct and their json names.
+// @param patterns, the regular expressions to mask in the message and the string, []byte, error and fmt.Stringer fields,
+//        only the groups are masked if the expression has any, eg: "token=(\\S+)".
+//        the []byte is matched as text and printed as the masked text when matched, the binary secrets are masked by the field names only.
+// @param mask, the text to replace the secrets, empty mean LOG_DEFAULT_REDACT_MASK.
+// @return error, the error of the patterns, the old rules are kept.
+func SetLogRedact(fields []string, patterns []string, mask string) error {
+	return loggerInst.setRedact(fields, patterns, mask)
+}
+
+//========================
+//      logRedactor
+//========================
+type logRedactType struct {
+	masks  []int // the fields to mask
+	nested []int // the fields which may contain secrets
+}
+
+type logRedactor struct {
+	fields   []string
+	patterns []*regexp.Regexp
+	mask     string
+	types    *sync.Map // reflect.Type -> *logRedactType
+}
+
+func newLogRedactor(fields []string, patterns []string, mask string) (*logRedactor, error) {
+	if len(mask) == 0 {
+		mask = LOG_DEFAULT_REDACT_MASK
+	}
+
+	r := &logRedactor{
+		fields:   make([]string, 0, len(fields)),
+		patterns: make([]*regexp.Regexp, 0, len(patterns)),
+		mask:     mask,
+		types:    &sync.Map{},
+	}
+
+	for _, field := range fields {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if len(field) > 0 {
+			r.fields = append(r.fields, field)
+		}
+	}
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		r.patterns = append(r.patterns, re)
+	}
+
+	return r, nil
+}
+
+// Check if the field should be masked.
+// @param name, the field name.
+// @return bool, true mean mask.
+func (r *logRedactor) matchField(name string) bool {
+	if len(name) == 0 || len(r.fields) == 0 {
+		return false
+	}
+
+	name = strings.ToLower(name)
+	for _, pattern := range r.fields {
+		if matchLogTag(pattern, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check if the text contains any secret of the patterns.
+// @param text, the text.
+// @return bool, true mean contain.
+func (r *logRedactor) matchText(text []byte) bool {
+	for _, re := range r.patterns {
+		if re.Match(text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mask the text matched by the patterns.
+// @param s, the text.
+// @return string, the masked text.
+// @return bool, true mean masked.
+func (r *logRedactor) maskText(s string) (string, bool) {
+	bMasked := false
+	for _, re := range r.patterns {
+		locs := re.FindAllStringSubmatchIndex(s, -1)
+		if len(locs) == 0 {
+			continue
+		}
+
+		s = r.replaceMatches(s, locs)
+		bMasked = true
+	}
+
+	return s, bMasked
+}
+
+func (r *logRedactor) replaceMatches(s string, locs [][]int) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	last := 0
+	for _, loc := range locs {
+		// mask the groups, or the whole match if no group
+		start := 0
+		if len(loc) > 2 {
+			start = 2
+		}
+
+		for i := start; i+1 < len(loc); i += 2 {
+			if loc[i] < last {
+				continue
+			}
+
+			b.WriteString(s[last:loc[i]])
+			b.WriteString(r.mask)
+			last = loc[i+1]
+		}
+	}
+
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// Mask the field.
+// @param field, the field.
+// @return LogField, the masked field.
+// @return bool, true mean masked.
+func (r *logRedactor) redactField(field LogField) (LogField, bool) {
+	if field.Value == nil {
+		return field, false
+	}
+
+	if r.matchField(field.Key) {
+		return LogField{Key: field.Key, Value: r.mask}, true
+	}
+
+	if str, ok := r.redactText(field.Value); ok {
+		return LogField{Key: field.Key, Value: str}, true
+	}
+
+	v, ok := r.redactValue(field.Value)
+	return LogField{Key: field.Key, Value: v}, ok
+}
+
+// Mask the formatted text of the string, []byte, error and fmt.Stringer.
+// @param v, the value.
+// @return string, the masked text.
+// @return bool, true mean masked.
+func (r *logRedactor) redactText(v interface{}) (string, bool) {
+	if len(r.patterns) == 0 {
+		return "", false
+	}
+
+	switch val := v.(type) {
+	case string:
+		return r.maskText(val)
+	case []byte:
+		return r.maskText(string(val))
+	case error, fmt.Stringer:
+		// same as printed, fmt recovers the panic of the nil receiver
+		return r.maskText(fmt.Sprint(val))
+	}
+
+	return "", false
+}
+
+// Mask the fields, the fields are copied if any is masked.
+// @param fields, the fields.
+// @return []LogField, the masked fields.
+// @return bool, true mean masked.
+func (r *logRedactor) redactFields(fields []LogField) ([]LogField, bool) {
+	var masked []LogField = nil
+	for i, field := range fields {
+		newField, ok := r.redactField(field)
+		if !ok {
+			continue
+		}
+
+		if masked == nil {
+			masked = make([]LogField, len(fields))
+			copy(masked, fields)
+		}
+
+		masked[i] = newField
+	}
+
+	if masked == nil {
+		return fields, false
+	}
+
+	return masked, true
+}
+
+// Mask the secret fields of the value, the value is copied if any is masked.
+// @param v, the value.
+// @return interface{}, the masked value.
+// @return bool, true mean masked.
+func (r *logRedactor) redactValue(v interface{}) (interface{}, bool) {
+	switch val := v.(type) {
+	case nil, string, error:
+		return v, false
+	case []byte:
+		// the message text is checked, but fmt prints the bytes as numbers
+		if str, ok := r.redactText(val); ok {
+			return str, true
+		}
+
+		return v, false
+	case LogField:
+		return r.redactField(val)
+	case []LogField:
+		return r.redactFields(val)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Struct, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Array:
+	default:
+		return v, false
+	}
+
+	nv, ok := r.redactReflect(rv, 0)
+	if !ok {
+		return v, false
+	}
+
+	return nv.Interface(), true
+}
+
+func (r *logRedactor) redactReflect(rv reflect.Value, depth int) (reflect.Value, bool) {
+	if depth > LOG_REDACT_MAX_DEPTH {
+		return rv, false
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+			return rv, false
+		}
+
+		ev, ok := r.redactStruct(rv.Elem(), depth)
+		if !ok {
+			return rv, false
+		}
+
+		p := reflect.New(ev.Type())
+		p.Elem().Set(ev)
+		return p, true
+
+	case reflect.Struct:
+		return r.redactStruct(rv, depth)
+
+	case reflect.Map:
+		return r.redactMap(rv, depth)
+
+	case reflect.Slice, reflect.Array:
+		return r.redactSlice(rv, depth)
+
+	case reflect.Interface:
+		if rv.IsNil() {
+			return rv, false
+		}
+
+		// the error and fmt.Stringer are printed as text
+		if rv.Type().NumMethod() == 0 && rv.CanInterface() {
+			str, ok := r.redactText(rv.Elem().Interface())
+			if ok {
+				return reflect.ValueOf(str), true
+			}
+		}
+
+		return r.redactReflect(rv.Elem(), depth+1)
+	}
+
+	return rv, false
+}
+
+// Mask the elements of the slice or the array, the elements are copied if any is masked.
+// @param rv, the slice or the array.
+// @param depth, the depth.
+// @return reflect.Value, the masked value.
+// @return bool, true mean masked.
+func (r *logRedactor) redactSlice(rv reflect.Value, depth int) (reflect.Value, bool) {
+	if rv.Kind() == reflect.Slice && rv.IsNil() {
+		return rv, false
+	}
+
+	if !r.isNestedType(rv.Type().Elem(), depth) {
+		return rv, false
+	}
+
+	var cp reflect.Value
+	bCopied := false
+	for i := 0; i < rv.Len(); i++ {
+		nv, ok := r.redactReflect(rv.Index(i), depth+1)
+		if !ok {
+			continue
+		}
+
+		if !bCopied {
+			if rv.Kind() == reflect.Slice {
+				cp = reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+				reflect.Copy(cp, rv)
+			} else {
+				cp = reflect.New(rv.Type()).Elem()
+				cp.Set(rv)
+			}
+
+			bCopied = true
+		}
+
+		cp.Index(i).Set(nv)
+	}
+
+	if !bCopied {
+		return rv, false
+	}
+
+	return cp, true
+}
+
+func (r *logRedactor) redactStruct(rv reflect.Value, depth int) (reflect.Value, bool) {
+	rt := r.getRedactType(rv.Type())
+	if rt == nil {
+		return rv, false
+	}
+
+	// never modify the value of the user
+	var cp reflect.Value
+	bCopied := false
+	copyStruct := func() {
+		if !bCopied {
+			cp = reflect.New(rv.Type()).Elem()
+			cp.Set(rv)
+			bCopied = true
+		}
+	}
+
+	for _, i := range rt.masks {
+		if rv.Field(i).IsZero() {
+			continue
+		}
+
+		copyStruct()
+		r.setMask(cp.Field(i))
+	}
+
+	for _, i := range rt.nested {
+		nv, ok := r.redactReflect(rv.Field(i), depth+1)
+		if !ok {
+			continue
+		}
+
+		copyStruct()
+		cp.Field(i).Set(nv)
+	}
+
+	if !bCopied {
+		return rv, false
+	}
+
+	return cp, true
+}
+
+func (r *logRedactor) redactMap(rv reflect.Value, depth int) (reflect.Value, bool) {
+	if rv.IsNil() || rv.Type().Key().Kind() != reflect.String {
+		return rv, false
+	}
+
+	var cp reflect.Value
+	bCopied := false
+	iter := rv.MapRange()
+	for iter.Next() {
+		k := iter.Key()
+		v := iter.Value()
+
+		var nv reflect.Value
+		ok := false
+		if r.matchField(k.String()) {
+			if !v.IsZero() {
+				nv = reflect.New(v.Type()).Elem()
+				r.setMask(nv)
+				ok = true
+			}
+		} else {
+			nv, ok = r.redactReflect(v, depth+1)
+		}
+
+		if !ok {
+			continue
+		}
+
+		if !bCopied {
+			cp = reflect.MakeMapWithSize(rv.Type(), rv.Len())
+			copyIter := rv.MapRange()
+			for copyIter.Next() {
+				cp.SetMapIndex(copyIter.Key(), copyIter.Value())
+			}
+
+			bCopied = true
+		}
+
+		cp.SetMapIndex(k, nv)
+	}
+
+	if !bCopied {
+		return rv, false
+	}
+
+	return cp, true
+}
+
+func (r *logRedactor) setMask(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(r.mask)
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(r.mask))
+		} else {
+			v.Set(reflect.Zero(v.Type()))
+		}
+
+	case reflect.Interface:
+		mv := reflect.ValueOf(r.mask)
+		if mv.Type().AssignableTo(v.Type()) {
+			v.Set(mv)
+		} else {
+			v.Set(reflect.Zero(v.Type()))
+		}
+
+	default:
+		v.Set(reflect.Zero(v.Type()))
+	}
+}
+
+// Get the fields to check of the struct type.
+// @param t, the struct type.
+// @return *logRedactType, nil mean the struct has no secret.
+func (r *logRedactor) getRedactType(t reflect.Type) *logRedactType {
+	obj, ok := r.types.Load(t)
+	if ok {
+		return obj.(*logRedactType)
+	}
+
+	rt := r.buildRedactType(t)
+	r.types.Store(t, rt)
+	return rt
+}
+
+func (r *logRedactor) buildRedactType(t reflect.Type) *logRedactType {
+	rt := &logRedactType{
+		masks:  nil,
+		nested: nil,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		// the unexported fields can't be set
+		if len(sf.PkgPath) > 0 {
+			continue
+		}
+
+		jsonName := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if r.matchField(sf.Name) || r.matchField(jsonName) {
+			rt.masks = append(rt.masks, i)
+			continue
+		}
+
+		if sf.Type.Kind() == reflect.Struct {
+			if r.getRedactType(sf.Type) != nil {
+				rt.nested = append(rt.nested, i)
+			}
+		} else if r.isNestedType(sf.Type, 0) {
+			rt.nested = append(rt.nested, i)
+		}
+	}
+
+	if len(rt.masks) == 0 && len(rt.nested) == 0 {
+		return nil
+	}
+
+	return rt
+}
+
+// Check if the value of the type may contain secrets, the struct is checked when redacting.
+// @param t, the type.
+// @param depth, the depth.
+// @return bool, true mean may contain.
+func (r *logRedactor) isNestedType(t reflect.Type, depth int) bool {
+	if depth > LOG_REDACT_MAX_DEPTH {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return t.Elem().Kind() == reflect.Struct
+
+	case reflect.Interface, reflect.Struct:
+		return true
+
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String
+
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() != reflect.Uint8 && r.isNestedType(t.Elem(), depth+1)
+	}
+
+	return false
+}
+
+//========================
+//     logger redact
+//========================
+func (l *logger) getRedactor() *logRedactor {
+	return l.redactor.Load().(*logRedactor)
+}
+
+func (l *logger) setRedact(fields []string, patterns []string, mask string) error {
+	if len(fields) == 0 && len(patterns) == 0 {
+		l.redactor.Store((*logRedactor)(nil))
+		return nil
+	}
+
+	r, err := newLogRedactor(fields, patterns, mask)
+	if err != nil {
+		return err
+	}
+
+	l.redactor.Store(r)
+	return nil
+}
+
+// Mask the secrets of the log, must be called in the writer goroutine.
+// @param info, the log.
+func (l *logger) redactLog(info *LogInfo) {
+	r := l.getRedactor()
+	if r == nil {
+		return
+	}
+
+	args, fields := info.Args, info.Fields
+	if len(info.Format) == 0 {
+		args, fields = l.splitLogArgs(info.Args, info.Fields)
+	}
+
+	bSplit := (len(args) != len(info.Args))
+	defer func() {
+		// release the references
+		for i := range l.redactArgs {
+			l.redactArgs[i] = nil
+		}
+
+		l.redactArgs = l.redactArgs[:0]
+	}()
+
+	// the structs in the message
+	bArgsMasked := false
+	for _, arg := range args {
+		v, ok := r.redactValue(arg)
+		l.redactArgs = append(l.redactArgs, v)
+		bArgsMasked = bArgsMasked || ok
+	}
+
+	// the message text
+	format := info.Format
+	if len(r.patterns) > 0 {
+		w := l.redactBuf
+		w.b = w.b[:0]
+		writeLogMsg(w, format, l.redactArgs, nil)
+		if r.matchText(w.b) {
+			msg, _ := r.maskText(string(w.b))
+			for i := range l.redactArgs {
+				l.redactArgs[i] = nil
+			}
+
+			l.redactArgs = append(l.redactArgs[:0], msg)
+			format = ""
+			bArgsMasked = true
+		}
+	}
+
+	fields, bFieldsMasked := r.redactFields(fields)
+	if !bArgsMasked && !bFieldsMasked {
+		return
+	}
+
+	for i := range info.argBuf {
+		info.argBuf[i] = nil
+	}
+
+	info.Format = format
+	info.argBuf = append(info.argBuf[:0], l.redactArgs...)
+	info.Args = info.argBuf
+
+	// the fields of the logger are shared, never modify them
+	if bFieldsMasked {
+		info.Fields = fields
+	} else if bSplit {
+		info.Fields = append([]LogField(nil), fields...)
+	}
+}
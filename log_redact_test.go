@@ -0,0 +1,163 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type testRedactLogin struct {
+	User   string `json:"user"`
+	Secret string `json:"password"`
+	Token  string
+}
+
+type testRedactSession struct {
+	Id     int
+	Login  *testRedactLogin
+	Extra  map[string]interface{}
+	Logins []testRedactLogin
+}
+
+type testRedactStringer struct {
+	text string
+}
+
+func (s testRedactStringer) String() string {
+	return s.text
+}
+
+func newTestRedactor(t *testing.T) *logRedactor {
+	r, err := newLogRedactor([]string{"password", "*token*"}, []string{"token=(\\S+)", "\\d{4}-\\d{4}-\\d{4}-\\d{4}"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+func TestRedactMaskText(t *testing.T) {
+	r := newTestRedactor(t)
+	cases := []struct {
+		text    string
+		want    string
+		bMasked bool
+	}{
+		{"login ok", "login ok", false},
+		{"login token=abc", "login token=******", true},
+		{"token=a token=b", "token=****** token=******", true},
+		{"card 1234-5678-1234-5678 paid", "card ****** paid", true},
+		{"token=abc card 1234-5678-1234-5678", "token=****** card ******", true},
+	}
+
+	for _, c := range cases {
+		got, bMasked := r.maskText(c.text)
+		if got != c.want || bMasked != c.bMasked {
+			t.Errorf("maskText(%q) = %q, %v, want %q, %v", c.text, got, bMasked, c.want, c.bMasked)
+		}
+	}
+}
+
+func TestRedactValue(t *testing.T) {
+	r := newTestRedactor(t)
+	login := testRedactLogin{User: "bob", Secret: "123456", Token: "abc"}
+	session := &testRedactSession{
+		Id:     1,
+		Login:  &login,
+		Extra:  map[string]interface{}{"api_token": "xyz", "note": "token=xyz", "nested": login},
+		Logins: []testRedactLogin{login},
+	}
+
+	maskedLogin := testRedactLogin{User: "bob", Secret: LOG_DEFAULT_REDACT_MASK, Token: LOG_DEFAULT_REDACT_MASK}
+	maskedSession := &testRedactSession{
+		Id:     1,
+		Login:  &maskedLogin,
+		Extra:  map[string]interface{}{"api_token": LOG_DEFAULT_REDACT_MASK, "note": "token=******", "nested": maskedLogin},
+		Logins: []testRedactLogin{maskedLogin},
+	}
+
+	cases := []struct {
+		name    string
+		v       interface{}
+		want    interface{}
+		bMasked bool
+	}{
+		{"no secret", 1, 1, false},
+		{"text arg", "token=abc", "token=abc", false},
+		{"struct", login, maskedLogin, true},
+		{"pointer", &login, &maskedLogin, true},
+		{"nested", session, maskedSession, true},
+		{"map", map[string]string{"Password": "123", "user": "bob"}, map[string]string{"Password": LOG_DEFAULT_REDACT_MASK, "user": "bob"}, true},
+		{"slice", []interface{}{login, "token=abc"}, []interface{}{maskedLogin, "token=******"}, true},
+		{"bytes", []byte("token=abc"), "token=******", true},
+		{"binary bytes", []byte{1, 2, 3}, []byte{1, 2, 3}, false},
+		{"field", LogKV("token", "abc"), LogKV("token", LOG_DEFAULT_REDACT_MASK), true},
+		{"error field", LogKV("err", errors.New("login token=abc")), LogKV("err", "login token=******"), true},
+		{"stringer field", LogKV("conn", testRedactStringer{"token=abc"}), LogKV("conn", "token=******"), true},
+		{"bytes field", LogKV("raw", []byte("token=abc")), LogKV("raw", "token=******"), true},
+	}
+
+	for _, c := range cases {
+		got, bMasked := r.redactValue(c.v)
+		if !reflect.DeepEqual(got, c.want) || bMasked != c.bMasked {
+			t.Errorf("%s: redactValue() = %#v, %v, want %#v, %v", c.name, got, bMasked, c.want, c.bMasked)
+		}
+	}
+
+	// never modify the value of the caller
+	if login.Secret != "123456" || login.Token != "abc" {
+		t.Errorf("login is modified: %+v", login)
+	}
+
+	if session.Login.Secret != "123456" || session.Extra["api_token"] != "xyz" || session.Logins[0].Secret != "123456" {
+		t.Errorf("session is modified: %+v", session)
+	}
+}
+
+func TestRedactLog(t *testing.T) {
+	l, c := NewCaptureLogger("redact")
+	defer l.StopLogger()
+
+	err := l.SetLogRedact([]string{"password"}, []string{"token=(\\S+)"}, "***")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := &testRedactLogin{User: "bob", Secret: "123456"}
+	l.I("login", login, LogKV("password", "123456"), "with token=abc", LogKV("user", "bob"))
+	l.With("password", "654321").I("child")
+	l.If("format token=%s", "abc")
+
+	logs := c.Logs()
+	if len(logs) != 3 {
+		t.Fatalf("Logs() = %d logs, want 3", len(logs))
+	}
+
+	// the message is printed by fmt.Sprint
+	wantMsg := fmt.Sprint("login", &testRedactLogin{User: "bob", Secret: "***"}, "with token=***") + " password=*** user=bob"
+	if logs[0].Msg != wantMsg {
+		t.Errorf("logs[0].Msg = %q, want %q", logs[0].Msg, wantMsg)
+	}
+
+	wantFields := []LogField{LogKV("password", "***"), LogKV("user", "bob")}
+	if !reflect.DeepEqual(logs[0].Fields, wantFields) {
+		t.Errorf("logs[0].Fields = %v, want %v", logs[0].Fields, wantFields)
+	}
+
+	if logs[1].Msg != "child password=***" {
+		t.Errorf("logs[1].Msg = %q", logs[1].Msg)
+	}
+
+	if logs[2].Msg != "format token=***" {
+		t.Errorf("logs[2].Msg = %q", logs[2].Msg)
+	}
+
+	if login.Secret != "123456" {
+		t.Errorf("login is modified: %+v", login)
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import "errors"
+
+var (
+	ErrLogNameIsEmpty = errors.New("log name is empty")
+	ErrLogNameExist   = errors.New("log name is exist")
+)
+
+var loggerRegistry = newLogRegistry()
+
+//========================
+//    global method
+//========================
+
+// Config the global logger and create the named loggers, see LogConf.Loggers.
+// The named loggers are started, the global logger is not started.
+// @param cfg, the config of the global logger, which declares the named loggers.
+// @param printFunc, the print function of console.
+// @return error, the error of the names, no logger is configured.
+func ConfigLoggers(cfg *LogConf, printFunc func(lv LogLv, logStr string)) error {
+	err := loggerRegistry.checkNames(cfg)
+	if err != nil {
+		return err
+	}
+
+	loggerInst.config(cfg, printFunc)
+	if len(cfg.Name) > 0 {
+		l := &IndependentLogger{
+			loggerImpl: loggerInst,
+		}
+
+		l.tag = cfg.Name
+		loggerRegistry.add(cfg.Name, l)
+	}
+
+	for _, subCfg := range cfg.Loggers {
+		l := NewIndependentLogger(subCfg.Name)
+		l.loggerImpl.config(subCfg, printFunc)
+		l.loggerImpl.start()
+		loggerRegistry.add(subCfg.Name, l)
+	}
+
+	return nil
+}
+
+// Load the config file, config the global logger and create the named loggers.
+// The global logger and the named loggers are reloaded when the file changed, the global logger must be started to watch the file.
+// The named loggers added or removed in the file are ignored until next ConfigLoggersByFile.
+// @param path, path of the json file.
+// @param decodeCb, a callback function to decode the content of the file.
+// @param printFunc, the print function of console.
+// @return error, error.
+func ConfigLoggersByFile(path string, decodeCb func(data []byte) ([]byte, error), printFunc func(lv LogLv, logStr string)) error {
+	cfg := &LogConf{}
+	err := LoadJsonConf(cfg, path, decodeCb)
+	if err != nil {
+		return err
+	}
+
+	err = ConfigLoggers(cfg, printFunc)
+	if err != nil {
+		return err
+	}
+
+	loggerInst.watchLoggersConf(path, decodeCb)
+	return nil
+}
+
+// Register a logger which is created by hand.
+// @param name, the name.
+// @param l, the logger.
+// @return error, error.
+func RegisterLogger(name string, l *IndependentLogger) error {
+	if len(name) == 0 {
+		return ErrLogNameIsEmpty
+	}
+
+	return loggerRegistry.addIfNotExist(name, l)
+}
+
+// Get the named logger.
+// @param name, the name.
+// @return *IndependentLogger, nil mean not exist.
+func GetLogger(name string) *IndependentLogger {
+	return loggerRegistry.get(name)
+}
+
+// Stop all the named loggers in the declared order, then stop the global logger.
+func StopAllLoggers() {
+	for _, l := range loggerRegistry.clear() {
+		if l.loggerImpl != loggerInst && l.loggerImpl.isRunning() {
+			l.loggerImpl.stop()
+		}
+	}
+
+	if loggerInst.isRunning() {
+		loggerInst.stop()
+	}
+}
+
+//========================
+//      logRegistry
+//========================
+type logRegistry struct {
+	lck     *FastLock
+	names   []string
+	loggers map[string]*IndependentLogger
+}
+
+func newLogRegistry() *logRegistry {
+	return &logRegistry{
+		lck:     NewFastLock(),
+		names:   make([]string, 0),
+		loggers: make(map[string]*IndependentLogger),
+	}
+}
+
+// Check the names are not empty, not duplicated and not registered.
+// @param cfg, the config of the global logger.
+// @return error, error.
+func (r *logRegistry) checkNames(cfg *LogConf) error {
+	if r.lck.TryLock(0) != nil {
+		return nil
+	}
+
+	defer r.lck.Unlock()
+
+	names := make(map[string]bool)
+	if len(cfg.Name) > 0 {
+		names[cfg.Name] = true
+	}
+
+	for _, subCfg := range cfg.Loggers {
+		if len(subCfg.Name) == 0 {
+			return ErrLogNameIsEmpty
+		}
+
+		if names[subCfg.Name] {
+			return ErrLogNameExist
+		}
+
+		names[subCfg.Name] = true
+	}
+
+	for name := range names {
+		_, ok := r.loggers[name]
+		if ok {
+			return ErrLogNameExist
+		}
+	}
+
+	return nil
+}
+
+func (r *logRegistry) add(name string, l *IndependentLogger) {
+	if r.lck.TryLock(0) != nil {
+		return
+	}
+
+	defer r.lck.Unlock()
+
+	r.names = append(r.names, name)
+	r.loggers[name] = l
+}
+
+func (r *logRegistry) addIfNotExist(name string, l *IndependentLogger) error {
+	if r.lck.TryLock(0) != nil {
+		return nil
+	}
+
+	defer r.lck.Unlock()
+
+	_, ok := r.loggers[name]
+	if ok {
+		return ErrLogNameExist
+	}
+
+	r.names = append(r.names, name)
+	r.loggers[name] = l
+	return nil
+}
+
+func (r *logRegistry) get(name string) *IndependentLogger {
+	if r.lck.TryLock(0) != nil {
+		return nil
+	}
+
+	defer r.lck.Unlock()
+
+	return r.loggers[name]
+}
+
+// Pass the configs to the named loggers, which reload them in their own loops.
+// @param cfg, the config of the global logger, which declares the named loggers.
+func (r *logRegistry) reloadLoggers(cfg *LogConf) {
+	if r.lck.TryLock(0) != nil {
+		return
+	}
+
+	defer r.lck.Unlock()
+
+	for _, subCfg := range cfg.Loggers {
+		l, ok := r.loggers[subCfg.Name]
+		if ok && l.loggerImpl != loggerInst {
+			l.loggerImpl.namedConf.Store(subCfg)
+		}
+	}
+}
+
+// Remove all the loggers.
+// @return []*IndependentLogger, the loggers in the registered order.
+func (r *logRegistry) clear() []*IndependentLogger {
+	if r.lck.TryLock(0) != nil {
+		return nil
+	}
+
+	defer r.lck.Unlock()
+
+	loggers := make([]*IndependentLogger, 0, len(r.names))
+	for _, name := range r.names {
+		loggers = append(loggers, r.loggers[name])
+	}
+
+	r.names = make([]string, 0)
+	r.loggers = make(map[string]*IndependentLogger)
+	return loggers
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A sink which records the order of closing.
+type testCloseOrderSink struct {
+	name   string
+	lck    *sync.Mutex
+	closed *[]string
+}
+
+func (s *testCloseOrderSink) WriteLogs(logs []*LogInfo) error {
+	return nil
+}
+
+func (s *testCloseOrderSink) Flush() error {
+	return nil
+}
+
+func (s *testCloseOrderSink) Close() error {
+	s.lck.Lock()
+	defer s.lck.Unlock()
+
+	*s.closed = append(*s.closed, s.name)
+	return nil
+}
+
+func TestLogRegistryCheckNames(t *testing.T) {
+	r := newLogRegistry()
+	r.add("registered", NewIndependentLogger("registered"))
+
+	cases := []struct {
+		name string
+		cfg  *LogConf
+		want error
+	}{
+		{"ok", &LogConf{Name: "app", Loggers: []*LogConf{{Name: "access"}, {Name: "audit"}}}, nil},
+		{"no global name", &LogConf{Loggers: []*LogConf{{Name: "access"}}}, nil},
+		{"empty name", &LogConf{Loggers: []*LogConf{{Name: "access"}, {Name: ""}}}, ErrLogNameIsEmpty},
+		{"duplicated name", &LogConf{Loggers: []*LogConf{{Name: "access"}, {Name: "access"}}}, ErrLogNameExist},
+		{"same as global", &LogConf{Name: "app", Loggers: []*LogConf{{Name: "app"}}}, ErrLogNameExist},
+		{"registered name", &LogConf{Loggers: []*LogConf{{Name: "registered"}}}, ErrLogNameExist},
+		{"registered global name", &LogConf{Name: "registered"}, ErrLogNameExist},
+	}
+
+	for _, c := range cases {
+		if err := r.checkNames(c.cfg); err != c.want {
+			t.Errorf("%s: checkNames() = %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestGetLogger(t *testing.T) {
+	defer StopAllLoggers()
+
+	cfg := &LogConf{Loggers: []*LogConf{{Name: "get_access"}, {Name: "get_audit"}}}
+	err := ConfigLoggers(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"get_access", "get_audit"} {
+		l := GetLogger(name)
+		if l == nil || l.tag != name || !l.loggerImpl.isRunning() {
+			t.Errorf("GetLogger(%s) = %v, want a running logger with the tag", name, l)
+		}
+	}
+
+	if l := GetLogger("get_none"); l != nil {
+		t.Errorf("GetLogger(get_none) = %v, want nil", l)
+	}
+
+	if err := ConfigLoggers(cfg, nil); err != ErrLogNameExist {
+		t.Errorf("ConfigLoggers() again = %v, want %v", err, ErrLogNameExist)
+	}
+
+	if err := RegisterLogger("", NewIndependentLogger("")); err != ErrLogNameIsEmpty {
+		t.Errorf("RegisterLogger(empty) = %v, want %v", err, ErrLogNameIsEmpty)
+	}
+
+	if err := RegisterLogger("get_access", NewIndependentLogger("get_access")); err != ErrLogNameExist {
+		t.Errorf("RegisterLogger(get_access) = %v, want %v", err, ErrLogNameExist)
+	}
+}
+
+func TestStopAllLoggersOrder(t *testing.T) {
+	lck := &sync.Mutex{}
+	closed := make([]string, 0)
+	names := []string{"stop_first", "stop_second", "stop_third"}
+	loggers := make([]*IndependentLogger, 0, len(names))
+	for _, name := range names {
+		l := NewIndependentLogger(name)
+		l.RemoveLogSink(LOG_SINK_NAME_CONSOLE)
+		l.AddLogSink("order", &testCloseOrderSink{name: name, lck: lck, closed: &closed}, LOG_LV_TRACE)
+		l.StartLogger()
+		err := RegisterLogger(name, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loggers = append(loggers, l)
+	}
+
+	StopAllLoggers()
+
+	if !reflect.DeepEqual(closed, names) {
+		t.Errorf("closed order = %v, want %v", closed, names)
+	}
+
+	for i, name := range names {
+		if loggers[i].loggerImpl.isRunning() {
+			t.Errorf("%s is running after StopAllLoggers", name)
+		}
+
+		if GetLogger(name) != nil {
+			t.Errorf("GetLogger(%s) is not nil after StopAllLoggers", name)
+		}
+	}
+}
+
+func TestConfigLoggersByFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.json")
+	err := ioutil.WriteFile(path, []byte(`{"loggers" : [{"name" : "reload_sub", "level" : 1}]}`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ConfigLoggersByFile(path, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer StopWatchLogConf()
+	defer StopAllLoggers()
+
+	StartLogger()
+	l := GetLogger("reload_sub")
+	if l == nil || l.loggerImpl.getLevel() != LOG_LV_INFO {
+		t.Fatalf("GetLogger(reload_sub) = %v, want the INFO level", l)
+	}
+
+	err = ioutil.WriteFile(path, []byte(`{"loggers" : [{"name" : "reload_sub", "level" : 3, "is_json_format" : true}]}`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for l.loggerImpl.getLevel() != LOG_LV_ERROR || !l.loggerImpl.isJsonFormat() {
+		if time.Now().After(deadline) {
+			t.Fatalf("the named logger is not reloaded, level = %d", l.loggerImpl.getLevel())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import "fmt"
+
+// A sink which can reopen its files, eg: after the files are moved by logrotate.
+type LogReopener interface {
+	// Reopen the files, it is called in any goroutine.
+	// @return error, error.
+	Reopen() error
+}
+
+//========================
+//    global method
+//========================
+
+// Reopen the files of all the running loggers, include the independent loggers.
+// WatchLogSignal calls it when receive SIGHUP.
+func ReopenLogFiles() {
+	for _, l := range getRunningLoggers() {
+		l.reopenSinks()
+	}
+}
+
+//========================
+//     logger reopen
+//========================
+func (l *logger) reopenSinks() {
+	if l.lckSinks.TryLock(0) != nil {
+		return
+	}
+
+	sinks := l.sinks
+	l.lckSinks.Unlock()
+
+	for _, entry := range sinks {
+		reopener, ok := entry.sink.(LogReopener)
+		if !ok {
+			continue
+		}
+
+		err := reopener.Reopen()
+		if err != nil {
+			fmt.Println("reopen log sink ", entry.name, " error: ", err)
+		}
+	}
+}
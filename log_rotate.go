@@ -0,0 +1,242 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	LOG_ROTATE_MODE_SIZE   = "size"
+	LOG_ROTATE_MODE_HOURLY = "hourly"
+	LOG_ROTATE_MODE_DAILY  = "daily"
+
+	LOG_ROTATE_QUEUE_SIZE = 64
+	LOG_COMPRESS_EXT      = ".gz"
+)
+
+//========================
+//     LogRotateConf
+//========================
+type LogRotateConf struct {
+	RotateMode  string `json:"rotate_mode"`   // size, hourly or daily, rotate by size is always open.
+	MaxBackups  int    `json:"max_backups"`   // max count of rotated files, 0 mean no limit.
+	MaxAgeHours int    `json:"max_age_hours"` // max age of rotated files, 0 mean no limit.
+	IsCompress  bool   `json:"is_compress"`   // gzip the rotated files.
+}
+
+//========================
+//      logRotator
+//========================
+type logRotator struct {
+	cfg          LogRotateConf
+	strDir       string
+	backupPrefix string
+	backupExt    string
+	chanRotated  chan string
+	evtStopSucc  *Event
+}
+
+// Create a rotator.
+// @param strFile, the dump file path.
+// @param cfg, the rotate config.
+// @return *logRotator, the rotator.
+func newLogRotator(strFile string, cfg *LogRotateConf) *logRotator {
+	name := path.Base(strFile)
+	ext := path.Ext(name)
+
+	r := &logRotator{
+		cfg:          *cfg,
+		strDir:       path.Dir(strFile),
+		backupPrefix: strings.TrimSuffix(name, ext) + "_",
+		backupExt:    ext,
+		chanRotated:  nil,
+		evtStopSucc:  NewEvent(),
+	}
+
+	if r.needPostRotate() {
+		r.chanRotated = make(chan string, LOG_ROTATE_QUEUE_SIZE)
+		go r.loop()
+	}
+
+	return r
+}
+
+// Get the period of the time, files in different period need to rotate.
+// @param t, the time.
+// @return int64, the period, 0 mean not rotate by time.
+func (r *logRotator) getPeriod(t time.Time) int64 {
+	switch r.cfg.RotateMode {
+	case LOG_ROTATE_MODE_HOURLY:
+		return int64(t.Year())*1000000 + int64(t.Month())*10000 + int64(t.Day())*100 + int64(t.Hour())
+
+	case LOG_ROTATE_MODE_DAILY:
+		return int64(t.Year())*10000 + int64(t.Month())*100 + int64(t.Day())
+
+	default:
+		return 0
+	}
+}
+
+func (r *logRotator) needPostRotate() bool {
+	return r.cfg.IsCompress || r.cfg.MaxBackups > 0 || r.cfg.MaxAgeHours > 0
+}
+
+// Notify a file has been rotated, the compression and cleanup will run in background.
+// @param rotatedFile, the rotated file path.
+func (r *logRotator) onRotated(rotatedFile string) {
+	if r.chanRotated == nil {
+		return
+	}
+
+	select {
+	case r.chanRotated <- rotatedFile:
+	default:
+		fmt.Println("log rotate queue is full, skip post rotate: ", rotatedFile)
+	}
+}
+
+func (r *logRotator) stop() {
+	if r.chanRotated == nil {
+		return
+	}
+
+	close(r.chanRotated)
+	r.evtStopSucc.Wait()
+}
+
+func (r *logRotator) loop() {
+	for rotatedFile := range r.chanRotated {
+		if r.cfg.IsCompress {
+			err := r.compress(rotatedFile)
+			if err != nil {
+				fmt.Println("compress log file error: ", err)
+			}
+		}
+
+		r.cleanup()
+	}
+
+	r.evtStopSucc.Close()
+}
+
+func (r *logRotator) compress(file string) error {
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	gzFile := file + LOG_COMPRESS_EXT
+	dst, err := os.OpenFile(gzFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+
+	w := gzip.NewWriter(dst)
+	_, err = io.Copy(w, src)
+	if err == nil {
+		err = w.Close()
+	}
+
+	closeErr := dst.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(gzFile)
+		return err
+	}
+
+	src.Close()
+	return os.Remove(file)
+}
+
+// Remove the rotated files which exceed the max backups or max age.
+func (r *logRotator) cleanup() {
+	if r.cfg.MaxBackups <= 0 && r.cfg.MaxAgeHours <= 0 {
+		return
+	}
+
+	backups, err := r.listBackups()
+	if err != nil {
+		fmt.Println("list log backups error: ", err)
+		return
+	}
+
+	// newest first
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ModTime().After(backups[j].ModTime())
+	})
+
+	expireTime := time.Now().Add(-time.Duration(r.cfg.MaxAgeHours) * time.Hour)
+	for i, fi := range backups {
+		bRemove := (r.cfg.MaxBackups > 0 && i >= r.cfg.MaxBackups)
+		if !bRemove && r.cfg.MaxAgeHours > 0 {
+			bRemove = fi.ModTime().Before(expireTime)
+		}
+
+		if bRemove {
+			err = os.Remove(path.Join(r.strDir, fi.Name()))
+			if err != nil && !os.IsNotExist(err) {
+				fmt.Println("remove log backup error: ", err)
+			}
+		}
+	}
+}
+
+func (r *logRotator) listBackups() ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(r.strDir)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]os.FileInfo, 0, len(infos))
+	for _, fi := range infos {
+		if fi.IsDir() || !r.isBackupName(fi.Name()) {
+			continue
+		}
+
+		backups = append(backups, fi)
+	}
+
+	return backups, nil
+}
+
+// Check the name is a rotated file, eg: gateway_20220101_120000_1.log or gateway_20220101_120000_1.log.gz.
+func (r *logRotator) isBackupName(name string) bool {
+	if !strings.HasPrefix(name, r.backupPrefix) {
+		return false
+	}
+
+	if !strings.HasSuffix(name, r.backupExt) && !strings.HasSuffix(name, r.backupExt+LOG_COMPRESS_EXT) {
+		return false
+	}
+
+	// the time part YYYYMMDD_
+	timePart := name[len(r.backupPrefix):]
+	idx := strings.Index(timePart, "_")
+	if idx < 6 {
+		return false
+	}
+
+	for i := 0; i < idx; i++ {
+		if timePart[i] < '0' || timePart[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
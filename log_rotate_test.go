@@ -0,0 +1,215 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Create the files with the ages.
+func createTestAgedFiles(t *testing.T, dir string, ages map[string]time.Duration) {
+	now := time.Now()
+	for name, age := range ages {
+		file := filepath.Join(dir, name)
+		err := ioutil.WriteFile(file, []byte(name), 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		modTime := now.Add(-age)
+		err = os.Chtimes(file, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func listTestDir(t *testing.T, dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(infos))
+	for _, fi := range infos {
+		names = append(names, fi.Name())
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func TestIsBackupName(t *testing.T) {
+	r := newLogRotator("logs/app.log", &LogRotateConf{})
+	cases := []struct {
+		name string
+		want bool
+	}{
+		{"app_220101_120000_00001.log", true},
+		{"app_220101_120000_00001.log.gz", true},
+		{"app_20220101_120000_1.log", true},
+		{"app.log", false},
+		{"app_220101_120000_00001.txt", false},
+		{"app_access_220101_120000_00001.log", false},
+		{"app_2201_120000_00001.log", false},
+		{"app_22010a_120000_00001.log", false},
+		{"other_220101_120000_00001.log", false},
+		{"app_notes.log", false},
+	}
+
+	for _, c := range cases {
+		if got := r.isBackupName(c.name); got != c.want {
+			t.Errorf("isBackupName(%q) = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestGetRotatePeriod(t *testing.T) {
+	tm := time.Date(2022, 1, 2, 15, 4, 5, 0, time.Local)
+	cases := []struct {
+		mode string
+		want int64
+	}{
+		{LOG_ROTATE_MODE_SIZE, 0},
+		{LOG_ROTATE_MODE_HOURLY, 2022010215},
+		{LOG_ROTATE_MODE_DAILY, 20220102},
+	}
+
+	for _, c := range cases {
+		r := newLogRotator("app.log", &LogRotateConf{RotateMode: c.mode})
+		if got := r.getPeriod(tm); got != c.want {
+			t.Errorf("getPeriod(%s) = %d, want %d", c.mode, got, c.want)
+		}
+	}
+}
+
+func TestRotatorCleanup(t *testing.T) {
+	// the files which are never removed
+	others := map[string]time.Duration{
+		"app.log":                        100 * time.Hour,
+		"app_notes.log":                  100 * time.Hour,
+		"other_220101_120000_00001.log":  100 * time.Hour,
+		"app_220101_120000_00001.txt":    100 * time.Hour,
+		"app_access_220101_120000_1.log": 100 * time.Hour,
+	}
+
+	backups := map[string]time.Duration{
+		"app_220101_120000_00001.log.gz": 5 * time.Hour,
+		"app_220101_130000_00002.log.gz": 4 * time.Hour,
+		"app_220101_140000_00003.log":    3 * time.Hour,
+		"app_220101_150000_00004.log":    2 * time.Hour,
+		"app_220101_160000_00005.log":    1 * time.Hour,
+	}
+
+	cases := []struct {
+		name string
+		cfg  LogRotateConf
+		kept []string
+	}{
+		{"no limit", LogRotateConf{}, []string{
+			"app_220101_120000_00001.log.gz", "app_220101_130000_00002.log.gz", "app_220101_140000_00003.log",
+			"app_220101_150000_00004.log", "app_220101_160000_00005.log"}},
+		{"max backups", LogRotateConf{MaxBackups: 2}, []string{"app_220101_150000_00004.log", "app_220101_160000_00005.log"}},
+		{"max age", LogRotateConf{MaxAgeHours: 3}, []string{"app_220101_150000_00004.log", "app_220101_160000_00005.log"}},
+		{"max backups and age", LogRotateConf{MaxBackups: 1, MaxAgeHours: 3}, []string{"app_220101_160000_00005.log"}},
+	}
+
+	for _, c := range cases {
+		dir := t.TempDir()
+		createTestAgedFiles(t, dir, others)
+		createTestAgedFiles(t, dir, backups)
+
+		r := newLogRotator(filepath.Join(dir, "app.log"), &c.cfg)
+		r.cleanup()
+		r.stop()
+
+		want := append([]string(nil), c.kept...)
+		for name := range others {
+			want = append(want, name)
+		}
+
+		sort.Strings(want)
+		if got := listTestDir(t, dir); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: files = %v, want %v", c.name, got, want)
+		}
+	}
+}
+
+func TestRotatorCompress(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app_220101_120000_00001.log")
+	err := ioutil.WriteFile(file, []byte("rotated logs\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newLogRotator(filepath.Join(dir, "app.log"), &LogRotateConf{IsCompress: true})
+	r.onRotated(file)
+	r.stop()
+
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("the rotated file is not removed after compression, err = %v", err)
+	}
+
+	f, err := os.Open(file + LOG_COMPRESS_EXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(gr)
+	if err != nil || string(data) != "rotated logs\n" {
+		t.Errorf("decompressed = %q, %v, want %q", data, err, "rotated logs\n")
+	}
+}
+
+func TestFileLogSinkRotate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.log")
+	sink := NewFileLogSink(file, 64)
+	sink.SetRotateConf(&LogRotateConf{MaxBackups: 2, IsCompress: true})
+	sink.SetBackup(filepath.Join(dir, "app.log.bak"), 0)
+
+	logs, _ := newBenchLogInfos(3)
+	for i := 0; i < 5; i++ {
+		err := sink.WriteLogs(logs)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sink.Close()
+
+	backupCnt := 0
+	for _, name := range listTestDir(t, dir) {
+		if name == "app.log" {
+			continue
+		}
+
+		if !strings.HasPrefix(name, "app_") || !strings.HasSuffix(name, ".log"+LOG_COMPRESS_EXT) {
+			t.Errorf("unexpected file %s", name)
+		}
+
+		backupCnt++
+	}
+
+	if backupCnt != 2 {
+		t.Errorf("count of backups = %d, want 2", backupCnt)
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"bytes"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	LOG_DEFAULT_SAMPLE_INTV = 1000
+	LOG_DEFAULT_DEDUPE_INTV = 10000
+)
+
+//========================
+//    global method
+//========================
+
+// Set the sampling of the logs. For every tag and call site,
+// print the first logs in every interval, then print 1 in every thereafter logs.
+// @param intervalMs, the interval in millisecond, 0 mean the default interval.
+// @param first, the count of logs to print in every interval, 0 mean close the sampling.
+// @param thereafter, print 1 in every thereafter logs after the first logs, 0 mean drop all.
+func SetLogSampling(intervalMs uint32, first int, thereafter int) {
+	loggerInst.sampler.setConf(intervalMs, first, thereafter)
+}
+
+// Collapse the identical consecutive logs to "last message repeated N times".
+// @param bDedupe, true mean open.
+// @param intervalMs, the max interval to report the repeated count, 0 mean the default interval.
+func SetLogDedupe(bDedupe bool, intervalMs uint32) {
+	loggerInst.dedupe.setConf(bDedupe, intervalMs)
+}
+
+//========================
+//     logSampleCounter
+//========================
+type logSampleKey struct {
+	tag string
+	pc  uintptr
+}
+
+type logSampleCounter struct {
+	lv          LogLv
+	tag         string
+	caller      string
+	windowStart time.Time
+	count       uint64
+	suppressed  uint64
+}
+
+//========================
+//       logSampler
+//========================
+type logSampler struct {
+	lck          *FastLock
+	bOpen        int32
+	intvMs       uint32
+	first        uint64
+	thereafter   uint64
+	counters     map[logSampleKey]*logSampleCounter
+	reports      []*LogInfo
+	lastScanTime time.Time
+}
+
+func newLogSampler() *logSampler {
+	return &logSampler{
+		lck:          NewFastLock(),
+		bOpen:        0,
+		intvMs:       LOG_DEFAULT_SAMPLE_INTV,
+		first:        0,
+		thereafter:   0,
+		counters:     make(map[logSampleKey]*logSampleCounter),
+		reports:      nil,
+		lastScanTime: time.Now(),
+	}
+}
+
+func (s *logSampler) setConf(intervalMs uint32, first int, thereafter int) {
+	if intervalMs == 0 {
+		intervalMs = LOG_DEFAULT_SAMPLE_INTV
+	}
+
+	if first < 0 {
+		first = 0
+	}
+
+	if thereafter < 0 {
+		thereafter = 0
+	}
+
+	if s.lck.TryLock(0) != nil {
+		return
+	}
+
+	defer s.lck.Unlock()
+
+	s.intvMs = intervalMs
+	s.first = uint64(first)
+	s.thereafter = uint64(thereafter)
+	if first > 0 {
+		atomic.StoreInt32(&s.bOpen, 1)
+	} else {
+		atomic.StoreInt32(&s.bOpen, 0)
+		s.counters = make(map[logSampleKey]*logSampleCounter)
+		s.reports = nil
+	}
+}
+
+func (s *logSampler) isOpen() bool {
+	return atomic.LoadInt32(&s.bOpen) == 1
+}
+
+// Check if the log can be printed.
+// @param lv, the level.
+// @param tag, the tag.
+// @param pc, the program counter of the call site.
+// @param callerConf, the caller config to format the call site.
+// @return bool, true mean print.
+func (s *logSampler) allow(lv LogLv, tag string, pc uintptr, callerConf *logCallerConf) bool {
+	if s.lck.TryLock(0) != nil {
+		return true
+	}
+
+	defer s.lck.Unlock()
+
+	now := time.Now()
+	key := logSampleKey{tag: tag, pc: pc}
+	c, ok := s.counters[key]
+	if !ok {
+		c = &logSampleCounter{
+			lv:          lv,
+			tag:         tag,
+			caller:      callerConf.getCaller(pc).caller,
+			windowStart: now,
+			count:       0,
+			suppressed:  0,
+		}
+
+		s.counters[key] = c
+	} else if now.Sub(c.windowStart) >= time.Duration(s.intvMs)*time.Millisecond {
+		s.closeWindow(c, now)
+	}
+
+	c.lv = lv
+	c.count++
+	if c.count <= s.first {
+		return true
+	}
+
+	if s.thereafter > 0 && (c.count-s.first)%s.thereafter == 0 {
+		return true
+	}
+
+	c.suppressed++
+	return false
+}
+
+// Take the reports of the closed windows, must be called in the logger loop.
+// @param bForce, true mean close all the windows, eg: the logger is stopping.
+// @return []*LogInfo, the reports.
+func (s *logSampler) takeReports(bForce bool) []*LogInfo {
+	if !s.isOpen() {
+		return nil
+	}
+
+	if s.lck.TryLock(0) != nil {
+		return nil
+	}
+
+	defer s.lck.Unlock()
+
+	now := time.Now()
+	intv := time.Duration(s.intvMs) * time.Millisecond
+	if bForce || now.Sub(s.lastScanTime) >= intv {
+		s.lastScanTime = now
+		for key, c := range s.counters {
+			if bForce || now.Sub(c.windowStart) >= intv {
+				s.closeWindow(c, now)
+				delete(s.counters, key)
+			}
+		}
+	}
+
+	reports := s.reports
+	s.reports = nil
+	return reports
+}
+
+// Close the window of the counter and record the suppressed count, must be called in lock.
+func (s *logSampler) closeWindow(c *logSampleCounter, now time.Time) {
+	if c.suppressed > 0 {
+		info := newLogInfo(c.lv, c.tag, nil, LogArgs(
+			"sampled out ", strconv.FormatUint(c.suppressed, 10), " logs in last ",
+			now.Sub(c.windowStart).Round(time.Millisecond).String()), false)
+		info.Caller = c.caller
+		s.reports = append(s.reports, info)
+	}
+
+	c.windowStart = now
+	c.count = 0
+	c.suppressed = 0
+}
+
+//========================
+//       logDedupe
+//========================
+type logDedupe struct {
+	bOpen       int32
+	intvMs      uint32
+	lastKey     []byte
+	keyBuf      *logBuffer
+	lastLv      LogLv
+	lastTag     string
+	lastCaller  string
+	lastTime    time.Time
+	repeatCnt   uint64
+	windowStart time.Time
+	bufLogs     []*LogInfo
+}
+
+func newLogDedupe() *logDedupe {
+	return &logDedupe{
+		bOpen:       0,
+		intvMs:      LOG_DEFAULT_DEDUPE_INTV,
+		lastKey:     make([]byte, 0, LOG_STR_BUILD_INIT_CAP),
+		keyBuf:      newLogBuffer(),
+		lastLv:      LOG_LV_INFO,
+		lastTag:     "",
+		lastCaller:  "",
+		lastTime:    time.Time{},
+		repeatCnt:   0,
+		windowStart: time.Now(),
+		bufLogs:     make([]*LogInfo, 0, LOG_BATCH_DUMP_COUNT),
+	}
+}
+
+func (d *logDedupe) setConf(bDedupe bool, intervalMs uint32) {
+	if intervalMs == 0 {
+		intervalMs = LOG_DEFAULT_DEDUPE_INTV
+	}
+
+	atomic.StoreUint32(&d.intvMs, intervalMs)
+	if bDedupe {
+		atomic.StoreInt32(&d.bOpen, 1)
+	} else {
+		atomic.StoreInt32(&d.bOpen, 0)
+	}
+}
+
+func (d *logDedupe) isOpen() bool {
+	return atomic.LoadInt32(&d.bOpen) == 1
+}
+
+// Make the report of the repeated count and reset the count.
+// @return *LogInfo, the report with the time of the last repeated log.
+func (d *logDedupe) takeReport() *LogInfo {
+	info := newLogInfo(d.lastLv, d.lastTag, nil, LogArgs(
+		"last message repeated ", strconv.FormatUint(d.repeatCnt, 10), " times"), false)
+	info.Caller = d.lastCaller
+	info.Time = d.lastTime
+	d.repeatCnt = 0
+	d.windowStart = time.Now()
+	return info
+}
+
+func (d *logDedupe) reset() {
+	d.lastKey = d.lastKey[:0]
+	d.lastTag = ""
+	d.lastCaller = ""
+	d.repeatCnt = 0
+}
+
+//========================
+//     logger sample
+//========================
+
+// Append the sampling reports to the write logs.
+func (l *logger) appendSampleReport() {
+	reports := l.sampler.takeReports(l.isStop())
+	if len(reports) > 0 {
+		l.writeLogs = append(l.writeLogs, reports...)
+	}
+}
+
+// Collapse the identical consecutive logs in the write logs.
+func (l *logger) collapseDupLogs() {
+	d := l.dedupe
+	if !d.isOpen() {
+		if d.repeatCnt > 0 {
+			l.writeLogs = append(l.writeLogs, d.takeReport())
+		}
+
+		d.reset()
+		return
+	}
+
+	logs := d.bufLogs[:0]
+	for _, info := range l.writeLogs {
+		if info.flushEvt != nil || info.Lv >= LOG_LV_FATAL {
+			logs = append(logs, info)
+			continue
+		}
+
+		key := l.buildDupKey(info, d.keyBuf)
+		if bytes.Equal(key, d.lastKey) {
+			d.repeatCnt++
+			d.lastTime = info.Time
+			releaseLogInfo(info)
+			continue
+		}
+
+		if d.repeatCnt > 0 {
+			logs = append(logs, d.takeReport())
+		}
+
+		d.lastKey = append(d.lastKey[:0], key...)
+		d.lastLv = info.Lv
+		d.lastTag = info.Tag
+		d.lastCaller = info.Caller
+		d.windowStart = info.Time
+		logs = append(logs, info)
+	}
+
+	// window closed
+	intv := time.Duration(atomic.LoadUint32(&d.intvMs)) * time.Millisecond
+	if d.repeatCnt > 0 && (time.Since(d.windowStart) >= intv || l.isStop()) {
+		logs = append(logs, d.takeReport())
+	}
+
+	for i := range l.writeLogs {
+		l.writeLogs[i] = nil
+	}
+
+	d.bufLogs = l.writeLogs[:0]
+	l.writeLogs = logs
+}
+
+// Build the key to compare the logs, must be called in the writer goroutine.
+// @param info, the log.
+// @param w, the buffer of the key.
+// @return []byte, the key, it is valid until next building.
+func (l *logger) buildDupKey(info *LogInfo, w *logBuffer) []byte {
+	args, fields := info.Args, info.Fields
+	if len(info.Format) == 0 {
+		args, fields = l.splitLogArgs(info.Args, info.Fields)
+	}
+
+	w.b = strconv.AppendInt(w.b[:0], int64(info.Lv), 10)
+	w.b = append(w.b, 0)
+	w.b = append(w.b, info.Tag...)
+	w.b = append(w.b, 0)
+	w.b = append(w.b, info.Caller...)
+	w.b = append(w.b, 0)
+	if info.IsDetail {
+		w.b = append(w.b, 1)
+	}
+
+	writeLogMsg(w, info.Format, args, fields)
+	return w.b
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getTestPc() uintptr {
+	pc, _, _, _ := runtime.Caller(1)
+	return pc
+}
+
+func TestLogSamplerAllow(t *testing.T) {
+	cases := []struct {
+		name       string
+		first      int
+		thereafter int
+		cnt        int
+		wantAllow  int
+	}{
+		{"below first", 5, 0, 3, 3},
+		{"drop after first", 2, 0, 10, 2},
+		{"1 in thereafter", 2, 3, 10, 4},
+		{"1 in 1", 2, 1, 10, 10},
+	}
+
+	callerConf := newLogCallerConf()
+	pc := getTestPc()
+	for _, c := range cases {
+		s := newLogSampler()
+		s.setConf(60000, c.first, c.thereafter)
+
+		allowCnt := 0
+		for i := 0; i < c.cnt; i++ {
+			if s.allow(LOG_LV_INFO, "sample", pc, callerConf) {
+				allowCnt++
+			}
+		}
+
+		if allowCnt != c.wantAllow {
+			t.Errorf("%s: allowed %d logs, want %d", c.name, allowCnt, c.wantAllow)
+		}
+
+		// another call site or tag is counted alone
+		if !s.allow(LOG_LV_INFO, "other", pc, callerConf) || !s.allow(LOG_LV_INFO, "sample", getTestPc(), callerConf) {
+			t.Errorf("%s: the first log of another key is not allowed", c.name)
+		}
+
+		reports := s.takeReports(true)
+		suppressed := c.cnt - c.wantAllow
+		if suppressed == 0 {
+			if len(reports) != 0 {
+				t.Errorf("%s: %d reports, want none", c.name, len(reports))
+			}
+
+			continue
+		}
+
+		if len(reports) != 1 {
+			t.Fatalf("%s: %d reports, want 1", c.name, len(reports))
+		}
+
+		w := newLogBuffer()
+		writeLogMsg(w, reports[0].Format, reports[0].Args, nil)
+		msg := string(w.b)
+		wantPrefix := "sampled out " + strconv.Itoa(suppressed) + " logs in last "
+		if !strings.HasPrefix(msg, wantPrefix) || reports[0].Tag != "sample" {
+			t.Errorf("%s: report = %q [%s], want %q", c.name, msg, reports[0].Tag, wantPrefix)
+		}
+	}
+}
+
+func TestLogSamplerWindow(t *testing.T) {
+	callerConf := newLogCallerConf()
+	pc := getTestPc()
+	s := newLogSampler()
+	s.setConf(20, 1, 0)
+
+	if !s.allow(LOG_LV_INFO, "sample", pc, callerConf) || s.allow(LOG_LV_INFO, "sample", pc, callerConf) {
+		t.Fatal("the first log is not allowed or the second is allowed")
+	}
+
+	if reports := s.takeReports(false); len(reports) != 0 {
+		t.Errorf("%d reports before the window closed, want none", len(reports))
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !s.allow(LOG_LV_INFO, "sample", pc, callerConf) {
+		t.Errorf("the first log of the next window is not allowed")
+	}
+
+	if reports := s.takeReports(false); len(reports) != 1 {
+		t.Errorf("%d reports after the window closed, want 1", len(reports))
+	}
+
+	// closing the sampling clears the counters
+	s.setConf(20, 0, 0)
+	if s.isOpen() || s.takeReports(true) != nil {
+		t.Errorf("the sampler is open or has reports after closed")
+	}
+}
+
+func TestLoggerSampling(t *testing.T) {
+	l, c := NewCaptureLogger("sample")
+	l.loggerImpl.sampler.setConf(60000, 2, 3)
+	for i := 0; i < 10; i++ {
+		l.I("sampled line", i)
+	}
+
+	l.StopLogger()
+
+	if n := c.Count(LOG_LV_TRACE, "sample", "sampled line"); n != 4 {
+		t.Errorf("Count(sampled line) = %d, want 4", n)
+	}
+
+	if !c.Contains(LOG_LV_INFO, "sample", "sampled out 6 logs") {
+		t.Errorf("the sampling report is not found, logs = %+v", c.Logs())
+	}
+}
+
+func TestLoggerDedupe(t *testing.T) {
+	l, c := NewCaptureLogger("dedupe")
+	l.loggerImpl.dedupe.setConf(true, 60000)
+	for i := 0; i < 5; i++ {
+		l.W("same line")
+	}
+
+	// the same call site
+	for i := 0; i < 2; i++ {
+		l.W("other line")
+	}
+
+	l.E("other line")
+	l.StopLogger()
+
+	want := []string{"same line", "last message repeated 4 times", "other line", "last message repeated 1 times", "other line"}
+	logs := c.Logs()
+	if len(logs) != len(want) {
+		t.Fatalf("logs = %+v, want %q", logs, want)
+	}
+
+	for i, log := range logs {
+		if log.Msg != want[i] {
+			t.Errorf("logs[%d] = %q, want %q", i, log.Msg, want[i])
+		}
+	}
+
+	// the report has the level and the tag of the repeated log
+	if logs[1].Lv != LOG_LV_WARN || logs[1].Tag != "dedupe" || logs[4].Lv != LOG_LV_ERROR {
+		t.Errorf("logs = %+v, the report and the level change are wrong", logs)
+	}
+}
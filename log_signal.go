@@ -0,0 +1,119 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"os"
+	"os/signal"
+	"strings"
+)
+
+const LOG_CONTROL_TAG = "logger"
+
+//========================
+//    global method
+//========================
+
+// Control the logger by signals, every change is logged.
+//   SIGUSR1, cycle the level: TRACE -> DEBUG -> INFO -> WARN -> ERROR -> TRACE.
+//   SIGUSR2, toggle showing the caller.
+//   SIGHUP, reopen the log files of all the running loggers, include the independent loggers.
+// Not support on windows.
+func WatchLogSignal() {
+	loggerInst.watchSignal()
+}
+
+// Stop controlling the logger by signals.
+func StopWatchLogSignal() {
+	loggerInst.stopWatchSignal()
+}
+
+//========================
+//     logger signal
+//========================
+func (l *logger) watchSignal() {
+	if len(logControlSignals) == 0 {
+		return
+	}
+
+	if l.lckSignal.TryLock(0) != nil {
+		return
+	}
+
+	defer l.lckSignal.Unlock()
+
+	if l.chanSignal != nil {
+		return
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, logControlSignals...)
+	l.chanSignal = ch
+
+	go func() {
+		for sig := range ch {
+			l.handleSignal(sig)
+		}
+	}()
+}
+
+func (l *logger) stopWatchSignal() {
+	if l.lckSignal.TryLock(0) != nil {
+		return
+	}
+
+	defer l.lckSignal.Unlock()
+
+	if l.chanSignal == nil {
+		return
+	}
+
+	signal.Stop(l.chanSignal)
+	close(l.chanSignal)
+	l.chanSignal = nil
+}
+
+// Cycle the level between LOG_LV_TRACE and LOG_LV_ERROR.
+// @param sig, the signal.
+func (l *logger) cycleLevel(sig os.Signal) {
+	lv := l.getLevel() + 1
+	if lv > LOG_LV_ERROR || lv < LOG_LV_TRACE {
+		lv = LOG_LV_TRACE
+	}
+
+	l.SetLevel(lv)
+	l.logControlChange("log level is changed to ", strings.TrimSpace(l.getLvStr(lv)), " by signal ", sig.String())
+}
+
+// Toggle showing the caller.
+// @param sig, the signal.
+func (l *logger) toggleShowCaller(sig os.Signal) {
+	bShowCaller := !l.isShowCaller()
+	l.SetShowCaller(bShowCaller)
+	if bShowCaller {
+		l.logControlChange("show caller is enabled by signal ", sig.String())
+	} else {
+		l.logControlChange("show caller is disabled by signal ", sig.String())
+	}
+}
+
+// Reopen the log files.
+// @param sig, the signal.
+func (l *logger) reopenBySignal(sig os.Signal) {
+	if l == loggerInst {
+		ReopenLogFiles()
+	} else {
+		l.reopenSinks()
+	}
+
+	l.logControlChange("log files are reopened by signal ", sig.String())
+}
+
+// Log the change of the logger, ignore the level of the logger.
+// @param a, the message.
+func (l *logger) logControlChange(a ...interface{}) {
+	info := newLogInfo(LOG_LV_WARN, LOG_CONTROL_TAG, nil, a, false)
+	l.pushLog(info)
+}
@@ -0,0 +1,28 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package yx
+
+import (
+	"os"
+	"syscall"
+)
+
+var logControlSignals = []os.Signal{syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGHUP}
+
+func (l *logger) handleSignal(sig os.Signal) {
+	switch sig {
+	case syscall.SIGUSR1:
+		l.cycleLevel(sig)
+
+	case syscall.SIGUSR2:
+		l.toggleShowCaller(sig)
+
+	case syscall.SIGHUP:
+		l.reopenBySignal(sig)
+	}
+}
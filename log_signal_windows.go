@@ -0,0 +1,16 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build windows
+// +build windows
+
+package yx
+
+import "os"
+
+// no SIGUSR1 and SIGUSR2 on windows
+var logControlSignals []os.Signal = nil
+
+func (l *logger) handleSignal(sig os.Signal) {
+}
@@ -0,0 +1,523 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const LOG_FILE_CHECK_INTV = 1000
+
+const (
+	LOG_SINK_TYPE_CONSOLE = "console"
+	LOG_SINK_TYPE_FILE    = "file"
+
+	LOG_SINK_NAME_CONSOLE = "console"
+	LOG_SINK_NAME_DUMP    = "dump"
+)
+
+var (
+	ErrLogSinkTypeNotExist = errors.New("log sink type not exist")
+	ErrLogSinkBuilderExist = errors.New("log sink builder is exist")
+)
+
+type LogSink interface {
+	// Write a batch of logs.
+	// The logs are reused after return, copy LogInfo.LogBuf if need to keep it.
+	// @param logs, the formatted logs, the content is in LogInfo.LogBuf.
+	// @return error, error.
+	WriteLogs(logs []*LogInfo) error
+
+	// Flush the buffered logs.
+	// @return error, error.
+	Flush() error
+
+	// Close the sink.
+	// @return error, error.
+	Close() error
+}
+
+//========================
+//     LogSinkConf
+//========================
+type LogSinkConf struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Level    *int   `json:"level"` // min level, nil mean the default of the type, ERROR for mail, TRACE (all the logs) for others
+	Path     string `json:"path"`
+	FileSize int    `json:"file_size"`
+	LogRotateConf
+	BakPath   string         `json:"bak_path"`   // empty mean the file name with ".bak" in the working directory
+	RetrySize int            `json:"retry_size"` // max count of logs kept in memory when fail to dump
+	Mail      *LogMailConf   `json:"mail"`
+	Net       *LogNetConf    `json:"net"`
+	Syslog    *LogSyslogConf `json:"syslog"`
+}
+
+// Get the min level of the sink.
+// @return LogLv, the level.
+func (cfg *LogSinkConf) GetLevel() LogLv {
+	if cfg.Level != nil {
+		return *cfg.Level
+	}
+
+	if cfg.Type == LOG_SINK_TYPE_MAIL {
+		return LOG_LV_ERROR
+	}
+
+	return LOG_LV_TRACE
+}
+
+type LogSinkBuilder = func(cfg *LogSinkConf) (LogSink, error)
+
+var mapType2LogSinkBuilder = make(map[string]LogSinkBuilder)
+var lckLogSinkBuilder = NewFastLock()
+
+// Register a builder to create the sink which is declared in LogConf.
+// @param sinkType, the type of the sink.
+// @param builder, the builder.
+// @return error, error.
+func RegisterLogSinkBuilder(sinkType string, builder LogSinkBuilder) error {
+	if lckLogSinkBuilder.TryLock(0) != nil {
+		return ErrTryLockFail
+	}
+
+	defer lckLogSinkBuilder.Unlock()
+
+	_, ok := mapType2LogSinkBuilder[sinkType]
+	if ok {
+		return ErrLogSinkBuilderExist
+	}
+
+	mapType2LogSinkBuilder[sinkType] = builder
+	return nil
+}
+
+func getLogSinkBuilder(sinkType string) (LogSinkBuilder, bool) {
+	if lckLogSinkBuilder.TryLock(0) != nil {
+		return nil, false
+	}
+
+	defer lckLogSinkBuilder.Unlock()
+
+	builder, ok := mapType2LogSinkBuilder[sinkType]
+	return builder, ok
+}
+
+//========================
+//     ConsoleLogSink
+//========================
+type ConsoleLogSink struct {
+	printFunc func(lv LogLv, logStr string)
+}
+
+func NewConsoleLogSink(printFunc func(lv LogLv, logStr string)) *ConsoleLogSink {
+	return &ConsoleLogSink{
+		printFunc: printFunc,
+	}
+}
+
+func (s *ConsoleLogSink) SetPrintFunc(printFunc func(lv LogLv, logStr string)) {
+	s.printFunc = printFunc
+}
+
+func (s *ConsoleLogSink) WriteLogs(logs []*LogInfo) error {
+	printFunc := s.printFunc
+	for _, info := range logs {
+		if printFunc != nil {
+			printFunc(info.Lv, string(info.LogBuf))
+		} else {
+			linuxPrint(info.Lv, string(info.LogBuf))
+		}
+	}
+
+	return nil
+}
+
+func (s *ConsoleLogSink) Flush() error {
+	return nil
+}
+
+func (s *ConsoleLogSink) Close() error {
+	return nil
+}
+
+func linuxPrint(lv LogLv, logStr string) {
+	logPrintStr := ""
+	if lv == LOG_LV_FATAL {
+		logPrintStr = fmt.Sprintf("%c[1;41;37m%s%c[0m", 0x1B, logStr, 0x1B)
+	} else if lv == LOG_LV_ERROR {
+		logPrintStr = fmt.Sprintf("%c[1;40;31m%s%c[0m", 0x1B, logStr, 0x1B)
+	} else if lv == LOG_LV_WARN {
+		logPrintStr = fmt.Sprintf("%c[1;40;33m%s%c[0m", 0x1B, logStr, 0x1B)
+	} else if lv == LOG_LV_DEBUG {
+		logPrintStr = fmt.Sprintf("%c[1;40;32m%s%c[0m", 0x1B, logStr, 0x1B)
+	} else if lv == LOG_LV_TRACE {
+		logPrintStr = fmt.Sprintf("%c[0;40;36m%s%c[0m", 0x1B, logStr, 0x1B)
+	} else {
+		logPrintStr = logStr
+	}
+	fmt.Print(logPrintStr)
+}
+
+//========================
+//      FileLogSink
+//========================
+type FileLogSink struct {
+	strFile       string
+	maxFileSize   int
+	fileSno       uint64
+	rotator       *logRotator
+	period        int64
+	file          *os.File
+	fileSize      int64
+	buf           []byte
+	bReopen       int32
+	lastCheckTime time.Time
+	bakFile       string
+	retrySize     int
+	retryLogs     []string
+	bDegraded     bool
+	bErrNotified  bool
+	errCb         func(file string, err error)
+}
+
+// Create a file sink which rotate the file by size, the file is kept open between writings.
+// @param file, the relative/full path of a file.
+// @param maxFileSize, max size of the file.
+func NewFileLogSink(file string, maxFileSize int) *FileLogSink {
+	if maxFileSize <= 0 {
+		maxFileSize = LOG_DEFAULT_DUMP_SIZE
+	}
+
+	s := &FileLogSink{
+		strFile:       file,
+		maxFileSize:   maxFileSize,
+		fileSno:       0,
+		rotator:       nil,
+		period:        0,
+		file:          nil,
+		fileSize:      0,
+		buf:           make([]byte, 0, LOG_STR_BUILD_INIT_CAP*LOG_BATCH_DUMP_COUNT),
+		bReopen:       0,
+		lastCheckTime: time.Now(),
+		bakFile:       "",
+		retrySize:     0,
+		retryLogs:     make([]string, 0),
+		bDegraded:     false,
+		bErrNotified:  false,
+		errCb:         nil,
+	}
+
+	s.SetRotateConf(&LogRotateConf{})
+	s.SetBackup("", 0)
+	return s
+}
+
+// Set the rotate config, must be called before writing logs.
+// @param cfg, the rotate config.
+func (s *FileLogSink) SetRotateConf(cfg *LogRotateConf) {
+	if s.rotator != nil {
+		s.rotator.stop()
+	}
+
+	s.rotator = newLogRotator(s.strFile, cfg)
+	s.period = 0
+
+	// the period of the exist file
+	fs, err := os.Stat(s.strFile)
+	if err == nil {
+		s.period = s.rotator.getPeriod(fs.ModTime())
+	}
+}
+
+// Write the logs to the file, the logs which fail to dump are kept and re-appended later.
+// @param logs, the logs.
+// @return error, the error which make the dumping degraded, nil when it is already degraded.
+func (s *FileLogSink) WriteLogs(logs []*LogInfo) error {
+	s.checkPeriod()
+	s.checkReopen()
+
+	// keep the order when degraded
+	if s.bDegraded {
+		err := s.recoverBacklog()
+		if err != nil {
+			s.backlog(logs, 0, err)
+			return nil
+		}
+	}
+
+	cnt, offset, err := s.dumpToFile(logs)
+	if err != nil {
+		s.backlog(logs[cnt:], offset, err)
+	}
+
+	return err
+}
+
+func (s *FileLogSink) Flush() error {
+	return nil
+}
+
+func (s *FileLogSink) Sync() error {
+	if s.file == nil {
+		return nil
+	}
+
+	return s.file.Sync()
+}
+
+// Reopen the file at the next writing, eg: the file is moved by logrotate.
+// It is safe to call in any goroutine.
+func (s *FileLogSink) Reopen() error {
+	atomic.StoreInt32(&s.bReopen, 1)
+	return nil
+}
+
+func (s *FileLogSink) Close() error {
+	if s.bDegraded && s.recoverBacklog() != nil {
+		s.spillToBak(len(s.retryLogs))
+	}
+
+	s.closeFile()
+	s.rotator.stop()
+	return nil
+}
+
+// Open the file if not opened, the size is counted in process after opened.
+// @return error, error.
+func (s *FileLogSink) openFile() error {
+	if s.file != nil {
+		return nil
+	}
+
+	f, err := os.OpenFile(s.strFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+
+	fs, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	s.file = f
+	s.fileSize = fs.Size()
+	s.lastCheckTime = time.Now()
+	return nil
+}
+
+func (s *FileLogSink) closeFile() {
+	if s.file == nil {
+		return
+	}
+
+	err := s.file.Close()
+	if err != nil {
+		fmt.Println("close log dump file error: ", err)
+	}
+
+	s.file = nil
+	s.fileSize = 0
+}
+
+// Close the file when asked to reopen or the file is moved/removed by others.
+func (s *FileLogSink) checkReopen() {
+	if s.file == nil {
+		return
+	}
+
+	if atomic.CompareAndSwapInt32(&s.bReopen, 1, 0) {
+		s.closeFile()
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(s.lastCheckTime) < LOG_FILE_CHECK_INTV*time.Millisecond {
+		return
+	}
+
+	s.lastCheckTime = now
+	pathFs, err := os.Stat(s.strFile)
+	if err != nil {
+		s.closeFile()
+		return
+	}
+
+	fs, err := s.file.Stat()
+	if err != nil || !os.SameFile(pathFs, fs) {
+		s.closeFile()
+		return
+	}
+
+	// truncated by others
+	if pathFs.Size() < s.fileSize {
+		s.fileSize = pathFs.Size()
+	}
+}
+
+// Rotate the file when the time period changed.
+func (s *FileLogSink) checkPeriod() {
+	period := s.rotator.getPeriod(time.Now())
+	if period == s.period {
+		return
+	}
+
+	bNeedRename := (s.period != 0)
+	s.period = period
+	if !bNeedRename {
+		return
+	}
+
+	fs, err := os.Stat(s.strFile)
+	if err != nil || fs.Size() == 0 {
+		return
+	}
+
+	// name by the last write time in the old period
+	err = s.renameDumpFile(fs.ModTime())
+	if err != nil {
+		fmt.Println("rename dump file error: ", err)
+	}
+}
+
+// Write the logs to the file.
+// @param logs, the logs.
+// @return int, the count of the logs which are written completely.
+// @return int, the written bytes of the next log.
+// @return error, error.
+func (s *FileLogSink) dumpToFile(logs []*LogInfo) (int, int, error) {
+	totalCnt := len(logs)
+	idx := 0
+
+	for idx < totalCnt {
+		err := s.openFile()
+		if err != nil {
+			return idx, 0, err
+		}
+
+		// batch size
+		batchSize := totalCnt - idx
+		if batchSize > LOG_BATCH_DUMP_COUNT {
+			batchSize = LOG_BATCH_DUMP_COUNT
+		}
+
+		// dump
+		n, err := s.batchDumpToFile(logs[idx : idx+batchSize])
+		if err != nil {
+			cnt, offset := countWrittenLogs(logs[idx:idx+batchSize], n)
+			return idx + cnt, offset, err
+		}
+
+		idx += batchSize
+
+		// rename
+		if s.fileSize >= int64(s.maxFileSize) {
+			renameErr := s.renameDumpFile(time.Now())
+			if renameErr != nil {
+				fmt.Println("rename dump file error: ", renameErr)
+			}
+		}
+	}
+
+	return idx, 0, nil
+}
+
+// Write a batch of logs to the opened file.
+// @param logs, the logs.
+// @return int, the written bytes.
+// @return error, error.
+func (s *FileLogSink) batchDumpToFile(logs []*LogInfo) (int, error) {
+	buf := s.buf[:0]
+	for _, info := range logs {
+		buf = append(buf, info.LogBuf...)
+	}
+
+	s.buf = buf
+	n, err := s.file.Write(buf)
+	s.fileSize += int64(n)
+	if err != nil {
+		fmt.Println("batchDumpToFile write error: ", err)
+		s.closeFile()
+	}
+
+	return n, err
+}
+
+// Rename the file to the backup name, the file is closed first.
+// @param t, the time in the backup name.
+// @return error, error.
+func (s *FileLogSink) renameDumpFile(t time.Time) error {
+	s.closeFile()
+	s.fileSno++
+	dir := path.Dir(s.strFile)
+	name := path.Base(s.strFile)
+	ext := path.Ext(name)
+	nameOnly := strings.TrimSuffix(name, ext)
+
+	builder := &strings.Builder{}
+	builder.WriteString(nameOnly)
+
+	FormatTimeStr("_YYMMDD_hhmmss_", t, builder)
+	FormatUint(s.fileSno, 5, false, builder)
+	builder.WriteString(ext)
+	newName := path.Join(dir, builder.String())
+
+	err := os.Rename(s.strFile, newName)
+	if err != nil {
+		return err
+	}
+
+	s.rotator.onRotated(newName)
+	return nil
+}
+
+//========================
+//      logSinkEntry
+//========================
+type logSinkEntry struct {
+	name  string
+	sink  LogSink
+	level LogLv
+}
+
+func newLogSinkEntry(name string, sink LogSink, level LogLv) *logSinkEntry {
+	return &logSinkEntry{
+		name:  name,
+		sink:  sink,
+		level: level,
+	}
+}
+
+// Write the logs which level is not lower than the entry level.
+// @param logs, the formatted logs.
+// @param filterLogs, a buffer for the filtered logs.
+// @return []*LogInfo, the buffer for reuse.
+func (e *logSinkEntry) writeLogs(logs []*LogInfo, filterLogs []*LogInfo) []*LogInfo {
+	filterLogs = filterLogs[0:0]
+	for _, info := range logs {
+		if info.Lv >= e.level {
+			filterLogs = append(filterLogs, info)
+		}
+	}
+
+	if len(filterLogs) == 0 {
+		return filterLogs
+	}
+
+	err := e.sink.WriteLogs(filterLogs)
+	if err != nil {
+		fmt.Println("log sink ", e.name, " write error: ", err)
+	}
+
+	return filterLogs
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+const BENCH_DUMP_FILE_SIZE = 1024 * 1024 * 1024
+
+var benchDumpBatchSizes = []int{1, 10, LOG_BATCH_DUMP_COUNT}
+
+func newBenchLogInfos(cnt int) ([]*LogInfo, int64) {
+	logs := make([]*LogInfo, 0, cnt)
+	size := int64(0)
+	for i := 0; i < cnt; i++ {
+		info := &LogInfo{}
+		info.LogBuf = []byte("[22/01/02 15:04:05.000] [ INFO] [bench] dump benchmark line " + strconv.Itoa(i) + "\n")
+		size += int64(len(info.LogBuf))
+		logs = append(logs, info)
+	}
+
+	return logs, size
+}
+
+// The dump path before the file is kept open: open, write, stat and close for every batch.
+// @param file, the dump file.
+// @param logs, the logs.
+// @return error, error.
+func reopenDumpToFile(file string, logs []*LogInfo) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, info := range logs {
+		_, err = w.Write(info.LogBuf)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = GetFileSize(file)
+	return err
+}
+
+func BenchmarkFileLogSinkDump(b *testing.B) {
+	for _, batchSize := range benchDumpBatchSizes {
+		b.Run("batch"+strconv.Itoa(batchSize), func(b *testing.B) {
+			file := filepath.Join(b.TempDir(), "bench.log")
+			sink := NewFileLogSink(file, BENCH_DUMP_FILE_SIZE)
+			sink.SetBackup(file+LOG_DUMP_BAK_EXT, 0)
+			defer sink.Close()
+
+			logs, size := newBenchLogInfos(batchSize)
+			b.SetBytes(size)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := sink.WriteLogs(logs)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReopenPerDump(b *testing.B) {
+	for _, batchSize := range benchDumpBatchSizes {
+		b.Run("batch"+strconv.Itoa(batchSize), func(b *testing.B) {
+			file := filepath.Join(b.TempDir(), "bench.log")
+			logs, size := newBenchLogInfos(batchSize)
+			b.SetBytes(size)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := reopenDumpToFile(file, logs)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
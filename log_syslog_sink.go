@@ -0,0 +1,466 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	LOG_SINK_TYPE_SYSLOG = "syslog"
+
+	LOG_SYSLOG_RFC5424 = "rfc5424"
+	LOG_SYSLOG_RFC3164 = "rfc3164"
+
+	LOG_SYSLOG_DEFAULT_NETWORK  = "unixgram"
+	LOG_SYSLOG_DEFAULT_ADDR     = "/dev/log"
+	LOG_SYSLOG_DEFAULT_FACILITY = "user"
+	LOG_SYSLOG_DEFAULT_TIMEOUT  = 5000
+
+	LOG_SYSLOG_DEFAULT_MIN_BACKOFF = 500
+	LOG_SYSLOG_DEFAULT_MAX_BACKOFF = 30000
+
+	LOG_SYSLOG_MAX_HOST_LEN   = 255
+	LOG_SYSLOG_MAX_APP_LEN    = 48
+	LOG_SYSLOG_MAX_MSGID_LEN  = 32
+	LOG_SYSLOG_RFC3164_TIME   = "Jan _2 15:04:05"
+	LOG_SYSLOG_RFC5424_TIME   = "2006-01-02T15:04:05.000000Z07:00"
+	LOG_SYSLOG_RFC5424_NIL    = "-"
+	LOG_SYSLOG_SEVERITY_CRIT  = 2
+	LOG_SYSLOG_SEVERITY_ERR   = 3
+	LOG_SYSLOG_SEVERITY_WARN  = 4
+	LOG_SYSLOG_SEVERITY_INFO  = 6
+	LOG_SYSLOG_SEVERITY_DEBUG = 7
+)
+
+var (
+	ErrLogSyslogConfIsNil         = errors.New("log syslog config is nil")
+	ErrLogSyslogFormatUnknown     = errors.New("unknown log syslog format")
+	ErrLogSyslogFacilityUnknown   = errors.New("unknown log syslog facility")
+	ErrLogSyslogNetworkNotSupport = errors.New("log syslog network not support")
+)
+
+var mapName2SyslogFacility = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+//========================
+//     LogSyslogConf
+//========================
+type LogSyslogConf struct {
+	Network  string `json:"network"`  // udp, tcp, unixgram or unix
+	Addr     string `json:"addr"`     // host:port or the socket path, empty mean /dev/log
+	Format   string `json:"format"`   // LOG_SYSLOG_XXX, empty mean rfc5424
+	Facility string `json:"facility"` // kern, user, daemon, local0 ... local7, etc.
+	AppName  string `json:"app_name"` // empty mean the program name
+	Hostname string `json:"hostname"` // empty mean the host name of the os
+	Timeout  uint32 `json:"timeout"`  // millisecond, dial and write timeout
+
+	MinBackoff uint32 `json:"min_backoff"` // millisecond, the first reconnect delay
+	MaxBackoff uint32 `json:"max_backoff"` // millisecond, the reconnect delay is doubled up to it
+}
+
+//========================
+//     SyslogLogSink
+//========================
+type SyslogLogSink struct {
+	cfg          LogSyslogConf
+	facility     int
+	pid          string
+	bStream      bool
+	conn         net.Conn
+	backoff      uint32
+	nextDialTime time.Time
+	dropCnt      uint64
+	bErrPrinted  bool
+	msgBuf       *logBuffer
+	argBuf       []interface{}
+	fieldBuf     []LogField
+	buf          []byte
+}
+
+// Create a sink which write the logs to syslog.
+// The level is mapped to the severity, the tag is the MSGID of rfc5424 and the TAG of rfc3164.
+// The stream networks use the octet counting framing of rfc6587.
+// @param cfg, the syslog config, the zero values mean the default values.
+// @return *SyslogLogSink, the sink.
+// @return error, error.
+func NewSyslogLogSink(cfg *LogSyslogConf) (*SyslogLogSink, error) {
+	s := &SyslogLogSink{
+		cfg:          *cfg,
+		facility:     0,
+		pid:          strconv.Itoa(os.Getpid()),
+		bStream:      false,
+		conn:         nil,
+		backoff:      0,
+		nextDialTime: time.Time{},
+		dropCnt:      0,
+		bErrPrinted:  false,
+		msgBuf:       newLogBuffer(),
+		argBuf:       nil,
+		fieldBuf:     nil,
+		buf:          make([]byte, 0, LOG_STR_BUILD_INIT_CAP*LOG_BATCH_DUMP_COUNT),
+	}
+
+	if len(s.cfg.Network) == 0 {
+		s.cfg.Network = LOG_SYSLOG_DEFAULT_NETWORK
+	}
+
+	switch s.cfg.Network {
+	case "udp", "udp4", "udp6", "unixgram":
+		s.bStream = false
+	case "tcp", "tcp4", "tcp6", "unix":
+		s.bStream = true
+	default:
+		return nil, ErrLogSyslogNetworkNotSupport
+	}
+
+	if len(s.cfg.Addr) == 0 {
+		s.cfg.Addr = LOG_SYSLOG_DEFAULT_ADDR
+	}
+
+	if len(s.cfg.Format) == 0 {
+		s.cfg.Format = LOG_SYSLOG_RFC5424
+	}
+
+	if s.cfg.Format != LOG_SYSLOG_RFC5424 && s.cfg.Format != LOG_SYSLOG_RFC3164 {
+		return nil, ErrLogSyslogFormatUnknown
+	}
+
+	if len(s.cfg.Facility) == 0 {
+		s.cfg.Facility = LOG_SYSLOG_DEFAULT_FACILITY
+	}
+
+	facility, ok := mapName2SyslogFacility[s.cfg.Facility]
+	if !ok {
+		return nil, ErrLogSyslogFacilityUnknown
+	}
+
+	s.facility = facility
+
+	if len(s.cfg.AppName) == 0 {
+		s.cfg.AppName = filepath.Base(os.Args[0])
+	}
+
+	if len(s.cfg.Hostname) == 0 {
+		s.cfg.Hostname, _ = os.Hostname()
+	}
+
+	if s.cfg.Timeout == 0 {
+		s.cfg.Timeout = LOG_SYSLOG_DEFAULT_TIMEOUT
+	}
+
+	if s.cfg.MinBackoff == 0 {
+		s.cfg.MinBackoff = LOG_SYSLOG_DEFAULT_MIN_BACKOFF
+	}
+
+	if s.cfg.MaxBackoff < s.cfg.MinBackoff {
+		s.cfg.MaxBackoff = LOG_SYSLOG_DEFAULT_MAX_BACKOFF
+		if s.cfg.MaxBackoff < s.cfg.MinBackoff {
+			s.cfg.MaxBackoff = s.cfg.MinBackoff
+		}
+	}
+
+	s.backoff = s.cfg.MinBackoff
+	return s, nil
+}
+
+func (s *SyslogLogSink) WriteLogs(logs []*LogInfo) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	// drop the logs without dialing until the next reconnect time
+	if s.isWaitingReconnect() {
+		s.dropCnt += uint64(len(logs))
+		return nil
+	}
+
+	// the stream networks write all the logs at once
+	if s.bStream {
+		buf := s.buf[:0]
+		for _, info := range logs {
+			buf = s.appendFrame(buf, info)
+		}
+
+		s.buf = buf
+		return s.write(buf, len(logs))
+	}
+
+	var firstErr error = nil
+	for i, info := range logs {
+		if s.isWaitingReconnect() {
+			s.dropCnt += uint64(len(logs) - i)
+			break
+		}
+
+		s.buf = s.appendMessage(s.buf[:0], info)
+		err := s.write(s.buf, 1)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// The logs are written at once, so nothing to flush.
+func (s *SyslogLogSink) Flush() error {
+	return nil
+}
+
+func (s *SyslogLogSink) Close() error {
+	s.closeConn()
+	return nil
+}
+
+// Write the data, reconnect and retry once when fail.
+// The reconnecting is delayed by the backoff when fail to dial or write.
+// @param data, the data.
+// @param cnt, the count of logs in the data.
+// @return error, error.
+func (s *SyslogLogSink) write(data []byte, cnt int) error {
+	var err error = nil
+	for i := 0; i < 2; i++ {
+		err = s.connect()
+		if err != nil {
+			break
+		}
+
+		s.conn.SetWriteDeadline(time.Now().Add(time.Duration(s.cfg.Timeout) * time.Millisecond))
+		_, err = s.conn.Write(data)
+		if err == nil {
+			s.onWriteSucc()
+			return nil
+		}
+
+		s.closeConn()
+	}
+
+	s.onDisconnected()
+
+	// print once until recovered, the logs are dropped
+	s.dropCnt += uint64(cnt)
+	if s.bErrPrinted {
+		return nil
+	}
+
+	s.bErrPrinted = true
+	return err
+}
+
+func (s *SyslogLogSink) connect() error {
+	if s.conn != nil {
+		return nil
+	}
+
+	conn, err := net.DialTimeout(s.cfg.Network, s.cfg.Addr, time.Duration(s.cfg.Timeout)*time.Millisecond)
+	if err != nil {
+		return err
+	}
+
+	s.conn = conn
+	return nil
+}
+
+// Check if the sink is waiting to reconnect.
+// @return bool, true mean the logs should be dropped.
+func (s *SyslogLogSink) isWaitingReconnect() bool {
+	return s.conn == nil && time.Now().Before(s.nextDialTime)
+}
+
+func (s *SyslogLogSink) onWriteSucc() {
+	s.backoff = s.cfg.MinBackoff
+	if !s.bErrPrinted {
+		return
+	}
+
+	s.bErrPrinted = false
+	fmt.Println("log syslog ", s.cfg.Addr, " is recovered")
+	if s.dropCnt > 0 {
+		fmt.Println("log syslog ", s.cfg.Addr, " dropped ", s.dropCnt, " logs")
+		s.dropCnt = 0
+	}
+}
+
+// Close the connection and delay the next dialing.
+func (s *SyslogLogSink) onDisconnected() {
+	s.closeConn()
+	s.nextDialTime = time.Now().Add(time.Duration(s.backoff) * time.Millisecond)
+	s.backoff *= 2
+	if s.backoff > s.cfg.MaxBackoff {
+		s.backoff = s.cfg.MaxBackoff
+	}
+}
+
+func (s *SyslogLogSink) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// Append a message with the octet counting framing.
+// @param buf, the buffer.
+// @param info, the log.
+// @return []byte, the buffer.
+func (s *SyslogLogSink) appendFrame(buf []byte, info *LogInfo) []byte {
+	// the length is known after the message is built, then move the message to make room for it
+	start := len(buf)
+	buf = s.appendMessage(buf, info)
+	msgLen := len(buf) - start
+
+	var head [24]byte
+	headBuf := strconv.AppendInt(head[:0], int64(msgLen), 10)
+	headBuf = append(headBuf, ' ')
+	buf = append(buf, headBuf...)
+	copy(buf[start+len(headBuf):], buf[start:start+msgLen])
+	copy(buf[start:], headBuf)
+	return buf
+}
+
+// Append a syslog message.
+// @param buf, the buffer.
+// @param info, the log.
+// @return []byte, the buffer.
+func (s *SyslogLogSink) appendMessage(buf []byte, info *LogInfo) []byte {
+	pri := s.facility*8 + getSyslogSeverity(info.Lv)
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(pri), 10)
+	buf = append(buf, '>')
+
+	if s.cfg.Format == LOG_SYSLOG_RFC3164 {
+		// <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG, the local daemon add the host name itself
+		buf = info.Time.AppendFormat(buf, LOG_SYSLOG_RFC3164_TIME)
+		buf = append(buf, ' ')
+		if s.cfg.Network != "unixgram" && s.cfg.Network != "unix" {
+			buf = appendSyslogField(buf, s.cfg.Hostname, LOG_SYSLOG_MAX_HOST_LEN)
+			buf = append(buf, ' ')
+		}
+
+		tag := info.Tag
+		if len(tag) == 0 {
+			tag = s.cfg.AppName
+		}
+
+		buf = appendSyslogField(buf, tag, LOG_SYSLOG_MAX_APP_LEN)
+		buf = append(buf, '[')
+		buf = append(buf, s.pid...)
+		buf = append(buf, ']', ':', ' ')
+	} else {
+		// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+		buf = append(buf, '1', ' ')
+		buf = info.Time.AppendFormat(buf, LOG_SYSLOG_RFC5424_TIME)
+		buf = append(buf, ' ')
+		buf = appendSyslogField(buf, s.cfg.Hostname, LOG_SYSLOG_MAX_HOST_LEN)
+		buf = append(buf, ' ')
+		buf = appendSyslogField(buf, s.cfg.AppName, LOG_SYSLOG_MAX_APP_LEN)
+		buf = append(buf, ' ')
+		buf = append(buf, s.pid...)
+		buf = append(buf, ' ')
+		buf = appendSyslogField(buf, info.Tag, LOG_SYSLOG_MAX_MSGID_LEN)
+		buf = append(buf, ' ')
+		buf = append(buf, LOG_SYSLOG_RFC5424_NIL...)
+		buf = append(buf, ' ')
+	}
+
+	// [caller func] msg fields
+	if len(info.Caller) > 0 {
+		buf = append(buf, '[')
+		buf = append(buf, info.Caller...)
+		if len(info.Func) > 0 {
+			buf = append(buf, ' ')
+			buf = append(buf, info.Func...)
+		}
+
+		buf = append(buf, ']', ' ')
+	}
+
+	args, fields := info.Args, info.Fields
+	if len(info.Format) == 0 {
+		args, fields, s.argBuf, s.fieldBuf = splitLogArgsTo(info.Args, info.Fields, s.argBuf, s.fieldBuf)
+	}
+
+	w := s.msgBuf
+	w.b = w.b[:0]
+	writeLogMsg(w, info.Format, args, fields)
+	buf = append(buf, w.b...)
+
+	if len(info.Stack) > 0 {
+		buf = append(buf, '\n')
+		buf = append(buf, info.Stack...)
+		for len(buf) > 0 && buf[len(buf)-1] == '\n' {
+			buf = buf[:len(buf)-1]
+		}
+	}
+
+	return buf
+}
+
+// Append a header field, the characters out of printable ascii are replaced with '_'.
+// @param buf, the buffer.
+// @param field, the field.
+// @param maxLen, the max length.
+// @return []byte, the buffer.
+func appendSyslogField(buf []byte, field string, maxLen int) []byte {
+	if len(field) == 0 {
+		return append(buf, LOG_SYSLOG_RFC5424_NIL...)
+	}
+
+	if len(field) > maxLen {
+		field = field[:maxLen]
+	}
+
+	for i := 0; i < len(field); i++ {
+		c := field[i]
+		if c < 33 || c > 126 {
+			c = '_'
+		}
+
+		buf = append(buf, c)
+	}
+
+	return buf
+}
+
+func getSyslogSeverity(lv LogLv) int {
+	switch {
+	case lv >= LOG_LV_FATAL:
+		return LOG_SYSLOG_SEVERITY_CRIT
+	case lv == LOG_LV_ERROR:
+		return LOG_SYSLOG_SEVERITY_ERR
+	case lv == LOG_LV_WARN:
+		return LOG_SYSLOG_SEVERITY_WARN
+	case lv == LOG_LV_INFO:
+		return LOG_SYSLOG_SEVERITY_INFO
+	default:
+		return LOG_SYSLOG_SEVERITY_DEBUG
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrLogLvWrongName    = errors.New("wrong log level name")
+	ErrLogTagRuleInvalid = errors.New("invalid tag level rule")
+)
+
+// Parse the log level.
+// @param str, the name (trace/debug/info/warn/error/fatal, case insensitive) or the number of the level.
+// @return LogLv, the level.
+// @return error, error.
+func ParseLogLv(str string) (LogLv, error) {
+	str = strings.TrimSpace(str)
+	switch strings.ToLower(str) {
+	case "trace":
+		return LOG_LV_TRACE, nil
+	case "debug":
+		return LOG_LV_DEBUG, nil
+	case "info":
+		return LOG_LV_INFO, nil
+	case "warn", "warning":
+		return LOG_LV_WARN, nil
+	case "error":
+		return LOG_LV_ERROR, nil
+	case "fatal":
+		return LOG_LV_FATAL, nil
+	}
+
+	lv, err := strconv.Atoi(str)
+	if err != nil {
+		return LOG_LV_DEBUG, ErrLogLvWrongName
+	}
+
+	return lv, nil
+}
+
+//========================
+//      logTagRule
+//========================
+type logTagRule struct {
+	pattern string
+	level   LogLv
+}
+
+// Match the tag, '*' in pattern match any sequence of characters.
+// @param tag, the tag.
+// @return bool, true mean matched.
+func (r *logTagRule) match(tag string) bool {
+	return matchLogTag(r.pattern, tag)
+}
+
+func matchLogTag(pattern string, tag string) bool {
+	idx := strings.IndexByte(pattern, '*')
+	if idx < 0 {
+		return pattern == tag
+	}
+
+	if !strings.HasPrefix(tag, pattern[:idx]) {
+		return false
+	}
+
+	pattern = pattern[idx+1:]
+	tag = tag[idx:]
+	if len(pattern) == 0 {
+		return true
+	}
+
+	for i := 0; i <= len(tag); i++ {
+		if matchLogTag(pattern, tag[i:]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//========================
+//     logTagLevels
+//========================
+type logTagLevelResult struct {
+	level    LogLv
+	bMatched bool
+}
+
+type logTagLevels struct {
+	lck      *FastLock
+	rules    []*logTagRule
+	ruleCnt  int32
+	cacheRef atomic.Value // *sync.Map, tag -> *logTagLevelResult
+}
+
+func newLogTagLevels() *logTagLevels {
+	t := &logTagLevels{
+		lck:     NewFastLock(),
+		rules:   nil,
+		ruleCnt: 0,
+	}
+
+	t.cacheRef.Store(&sync.Map{})
+	return t
+}
+
+// Set the level of the tags which match the pattern.
+// @param pattern, the pattern of the tags, eg: net.*
+// @param lv, the level.
+func (t *logTagLevels) setLevel(pattern string, lv LogLv) {
+	if t.lck.TryLock(0) != nil {
+		return
+	}
+
+	defer t.lck.Unlock()
+
+	rules := make([]*logTagRule, 0, len(t.rules)+1)
+	for _, rule := range t.rules {
+		if rule.pattern != pattern {
+			rules = append(rules, rule)
+		}
+	}
+
+	rules = append(rules, &logTagRule{pattern: pattern, level: lv})
+	t.updateRules(rules)
+}
+
+// Replace all the rules.
+// @param rulesStr, the rules, eg: "net.*=debug, db=warn".
+// @return error, error.
+func (t *logTagLevels) setRules(rulesStr string) error {
+	rules, err := t.parseRules(rulesStr)
+	if err != nil {
+		return err
+	}
+
+	if t.lck.TryLock(0) != nil {
+		return ErrTryLockFail
+	}
+
+	defer t.lck.Unlock()
+
+	t.updateRules(rules)
+	return nil
+}
+
+func (t *logTagLevels) clear() {
+	if t.lck.TryLock(0) != nil {
+		return
+	}
+
+	defer t.lck.Unlock()
+
+	t.updateRules(nil)
+}
+
+func (t *logTagLevels) parseRules(rulesStr string) ([]*logTagRule, error) {
+	rules := make([]*logTagRule, 0)
+	items := strings.Split(rulesStr, ",")
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, ErrLogTagRuleInvalid
+		}
+
+		pattern := strings.TrimSpace(kv[0])
+		if len(pattern) == 0 {
+			return nil, ErrLogTagRuleInvalid
+		}
+
+		lv, err := ParseLogLv(kv[1])
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, &logTagRule{pattern: pattern, level: lv})
+	}
+
+	return rules, nil
+}
+
+func (t *logTagLevels) updateRules(rules []*logTagRule) {
+	t.rules = rules
+	t.cacheRef.Store(&sync.Map{})
+	atomic.StoreInt32(&t.ruleCnt, int32(len(rules)))
+}
+
+// Get the level of the tag.
+// @param tag, the tag.
+// @param defaultLv, the level if no rule matched.
+// @return LogLv, the level.
+func (t *logTagLevels) getLevel(tag string, defaultLv LogLv) LogLv {
+	if atomic.LoadInt32(&t.ruleCnt) == 0 {
+		return defaultLv
+	}
+
+	cache := t.cacheRef.Load().(*sync.Map)
+	v, ok := cache.Load(tag)
+	if !ok {
+		v = t.matchRules(tag)
+		cache.Store(tag, v)
+	}
+
+	result := v.(*logTagLevelResult)
+	if !result.bMatched {
+		return defaultLv
+	}
+
+	return result.level
+}
+
+// Find the rule which match the tag, the exact pattern first, then the longest pattern.
+func (t *logTagLevels) matchRules(tag string) *logTagLevelResult {
+	result := &logTagLevelResult{
+		level:    LOG_LV_DEBUG,
+		bMatched: false,
+	}
+
+	if t.lck.TryLock(0) != nil {
+		return result
+	}
+
+	defer t.lck.Unlock()
+
+	var matchRule *logTagRule = nil
+	for _, rule := range t.rules {
+		if !rule.match(tag) {
+			continue
+		}
+
+		if rule.pattern == tag {
+			matchRule = rule
+			break
+		}
+
+		if matchRule == nil || len(rule.pattern) > len(matchRule.pattern) {
+			matchRule = rule
+		}
+	}
+
+	if matchRule != nil {
+		result.level = matchRule.level
+		result.bMatched = true
+	}
+
+	return result
+}
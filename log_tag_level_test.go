@@ -0,0 +1,149 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import "testing"
+
+func TestParseLogLv(t *testing.T) {
+	cases := []struct {
+		str  string
+		want LogLv
+		err  error
+	}{
+		{"trace", LOG_LV_TRACE, nil},
+		{"DEBUG", LOG_LV_DEBUG, nil},
+		{" Info ", LOG_LV_INFO, nil},
+		{"warn", LOG_LV_WARN, nil},
+		{"warning", LOG_LV_WARN, nil},
+		{"error", LOG_LV_ERROR, nil},
+		{"fatal", LOG_LV_FATAL, nil},
+		{"3", LOG_LV_ERROR, nil},
+		{"-1", LOG_LV_TRACE, nil},
+		{"", LOG_LV_DEBUG, ErrLogLvWrongName},
+		{"verbose", LOG_LV_DEBUG, ErrLogLvWrongName},
+	}
+
+	for _, c := range cases {
+		lv, err := ParseLogLv(c.str)
+		if lv != c.want || err != c.err {
+			t.Errorf("ParseLogLv(%q) = %d, %v, want %d, %v", c.str, lv, err, c.want, c.err)
+		}
+	}
+}
+
+func TestMatchLogTag(t *testing.T) {
+	cases := []struct {
+		pattern string
+		tag     string
+		want    bool
+	}{
+		{"net", "net", true},
+		{"net", "net.tcp", false},
+		{"net.*", "net.tcp", true},
+		{"net.*", "net.", true},
+		{"net.*", "net", false},
+		{"net.*", "network", false},
+		{"*", "", true},
+		{"*", "any", true},
+		{"*.db", "user.db", true},
+		{"*.db", "user.dbx", false},
+		{"net.*.read", "net.tcp.read", true},
+		{"net.*.read", "net.tcp.write", false},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxcyyb", false},
+		{"a**c", "abc", true},
+	}
+
+	for _, c := range cases {
+		if got := matchLogTag(c.pattern, c.tag); got != c.want {
+			t.Errorf("matchLogTag(%q, %q) = %v, want %v", c.pattern, c.tag, got, c.want)
+		}
+	}
+}
+
+func TestParseTagRules(t *testing.T) {
+	cases := []struct {
+		rules string
+		want  []logTagRule
+		err   error
+	}{
+		{"", []logTagRule{}, nil},
+		{"net.*=debug, db=warn", []logTagRule{{"net.*", LOG_LV_DEBUG}, {"db", LOG_LV_WARN}}, nil},
+		{" net = 3 ,, ", []logTagRule{{"net", LOG_LV_ERROR}}, nil},
+		{"net", nil, ErrLogTagRuleInvalid},
+		{"=debug", nil, ErrLogTagRuleInvalid},
+		{"net=verbose", nil, ErrLogLvWrongName},
+	}
+
+	levels := newLogTagLevels()
+	for _, c := range cases {
+		rules, err := levels.parseRules(c.rules)
+		if err != c.err {
+			t.Errorf("parseRules(%q) error = %v, want %v", c.rules, err, c.err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		if len(rules) != len(c.want) {
+			t.Errorf("parseRules(%q) = %d rules, want %d", c.rules, len(rules), len(c.want))
+			continue
+		}
+
+		for i, rule := range rules {
+			if *rule != c.want[i] {
+				t.Errorf("parseRules(%q)[%d] = %+v, want %+v", c.rules, i, *rule, c.want[i])
+			}
+		}
+	}
+}
+
+func TestTagLevels(t *testing.T) {
+	levels := newLogTagLevels()
+	err := levels.setRules("net.*=warn, net.tcp.*=debug, net.tcp.conn=error, *=info")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		tag  string
+		want LogLv
+	}{
+		{"net.udp", LOG_LV_WARN},
+		{"net.tcp.read", LOG_LV_DEBUG},
+		{"net.tcp.conn", LOG_LV_ERROR},
+		{"db", LOG_LV_INFO},
+	}
+
+	for _, c := range cases {
+		// the second time is from the cache
+		for i := 0; i < 2; i++ {
+			if lv := levels.getLevel(c.tag, LOG_LV_TRACE); lv != c.want {
+				t.Errorf("getLevel(%q) = %d, want %d", c.tag, lv, c.want)
+			}
+		}
+	}
+
+	// the cache is cleared when the rules changed
+	levels.setLevel("net.*", LOG_LV_FATAL)
+	if lv := levels.getLevel("net.udp", LOG_LV_TRACE); lv != LOG_LV_FATAL {
+		t.Errorf("getLevel(net.udp) after setLevel = %d, want %d", lv, LOG_LV_FATAL)
+	}
+
+	if err := levels.setRules("net=bad"); err == nil {
+		t.Errorf("setRules(net=bad) = nil, want error")
+	}
+
+	if lv := levels.getLevel("net.udp", LOG_LV_TRACE); lv != LOG_LV_FATAL {
+		t.Errorf("getLevel(net.udp) after a wrong setRules = %d, the old rules are not kept", lv)
+	}
+
+	levels.clear()
+	if lv := levels.getLevel("net.udp", LOG_LV_TRACE); lv != LOG_LV_TRACE {
+		t.Errorf("getLevel(net.udp) after clear = %d, want the default level", lv)
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"runtime"
+	"strings"
+)
+
+const (
+	LOG_WRITER_MAX_LINE_SIZE = 64 * 1024
+	LOG_WRITER_AUTO_SKIP     = -1 // skip the frames of the io packages, eg: fmt.Fprintln -> Write
+	LOG_WRITER_MAX_IO_FRAMES = 8
+	LOG_STD_CALLER_SKIP      = 2 // log.Printf -> log.(*Logger).output -> Write
+)
+
+var logWriterIoPkgs = []string{"fmt.", "io.", "bufio.", "log."}
+
+//========================
+//    global method
+//========================
+
+// Create a writer which print every line as a log.
+// The caller is the code which call Write, the frames of fmt, io, bufio and log are skipped, eg: fmt.Fprintln(w, ...).
+// @param lv, the level of the logs.
+// @param tag, the tag of the logs.
+// @return *LogWriter, the writer.
+func NewLogWriter(lv LogLv, tag string) *LogWriter {
+	return newLogWriter(loggerInst, lv, tag, LOG_WRITER_AUTO_SKIP)
+}
+
+// Create a standard logger which print to the logger, eg: the ErrorLog of http.Server.
+// @param lv, the level of the logs.
+// @param tag, the tag of the logs.
+// @return *log.Logger, the standard logger.
+func NewStdLogger(lv LogLv, tag string) *log.Logger {
+	return log.New(newLogWriter(loggerInst, lv, tag, LOG_STD_CALLER_SKIP), "", 0)
+}
+
+// Redirect the output of the standard log package to the logger.
+// The flags of the standard log are cleared, the time and the caller are printed by the logger.
+// @param lv, the level of the logs.
+// @param tag, the tag of the logs.
+// @return func(), the function to restore the output and the flags.
+func RedirectStdLog(lv LogLv, tag string) func() {
+	return redirectStdLog(newLogWriter(loggerInst, lv, tag, LOG_STD_CALLER_SKIP))
+}
+
+func redirectStdLog(w *LogWriter) func() {
+	oldOutput := log.Writer()
+	oldFlags := log.Flags()
+	log.SetOutput(w)
+	log.SetFlags(0)
+
+	return func() {
+		log.SetOutput(oldOutput)
+		log.SetFlags(oldFlags)
+		w.Close()
+	}
+}
+
+//========================
+//       LogWriter
+//========================
+
+// An io.Writer which print every line as a log, the incomplete line is kept until the line break.
+type LogWriter struct {
+	loggerImpl *logger
+	lv         LogLv
+	tag        string
+	callerSkip int
+	lck        *FastLock
+	line       []byte
+}
+
+func newLogWriter(l *logger, lv LogLv, tag string, callerSkip int) *LogWriter {
+	return &LogWriter{
+		loggerImpl: l,
+		lv:         lv,
+		tag:        tag,
+		callerSkip: callerSkip,
+		lck:        NewFastLock(),
+		line:       nil,
+	}
+}
+
+// Write the data, every line is printed as a log, the empty lines are ignored.
+// @param p, the data.
+// @return int, always len(p).
+// @return error, nil.
+func (w *LogWriter) Write(p []byte) (int, error) {
+	if w.lck.TryLock(0) != nil {
+		return 0, nil
+	}
+
+	defer w.lck.Unlock()
+
+	data := p
+	if len(w.line) > 0 {
+		w.line = append(w.line, p...)
+		data = w.line
+	}
+
+	for {
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			break
+		}
+
+		w.printLine(data[:idx])
+		data = data[idx+1:]
+	}
+
+	// the long line is printed without waiting the line break
+	if len(data) >= LOG_WRITER_MAX_LINE_SIZE {
+		w.printLine(data)
+		data = nil
+	}
+
+	w.line = append(w.line[:0], data...)
+	return len(p), nil
+}
+
+// Print the incomplete line.
+// @return error, nil.
+func (w *LogWriter) Close() error {
+	if w.lck.TryLock(0) != nil {
+		return nil
+	}
+
+	defer w.lck.Unlock()
+
+	w.printLine(w.line)
+	w.line = w.line[:0]
+	return nil
+}
+
+func (w *LogWriter) printLine(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) == 0 || !w.loggerImpl.isEnabled(w.tag, w.lv) {
+		return
+	}
+
+	skip := w.callerSkip
+	if skip == LOG_WRITER_AUTO_SKIP {
+		skip = countLogWriterIoFrames()
+	}
+
+	args := [1]interface{}{string(line)}
+	w.loggerImpl.printLogSkip(skip, w.lv, w.tag, nil, "", args[:], false)
+}
+
+// Count the frames of the io packages which call Write, must be called by printLine.
+// @return int, the count.
+func countLogWriterIoFrames() int {
+	// runtime.Callers -> countLogWriterIoFrames -> printLine -> Write
+	pcs := [LOG_WRITER_MAX_IO_FRAMES]uintptr{}
+	n := runtime.Callers(4, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	cnt := 0
+	for {
+		frame, more := frames.Next()
+		if !isLogWriterIoFunc(frame.Function) {
+			break
+		}
+
+		cnt++
+		if !more {
+			break
+		}
+	}
+
+	return cnt
+}
+
+func isLogWriterIoFunc(funcName string) bool {
+	for _, pkg := range logWriterIoPkgs {
+		if strings.HasPrefix(funcName, pkg) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//========================
+//     WriterLogSink
+//========================
+
+// A sink which write the logs to an io.Writer, eg: os.Stderr, a bufio.Writer or a net.Conn.
+type WriterLogSink struct {
+	w   io.Writer
+	buf []byte
+}
+
+// Create a sink of the writer, the writer is not closed by the sink.
+// @param w, the writer.
+// @return *WriterLogSink, the sink.
+func NewWriterLogSink(w io.Writer) *WriterLogSink {
+	return &WriterLogSink{
+		w:   w,
+		buf: make([]byte, 0, LOG_STR_BUILD_INIT_CAP),
+	}
+}
+
+func (s *WriterLogSink) WriteLogs(logs []*LogInfo) error {
+	// one writing for the batch
+	s.buf = s.buf[:0]
+	for _, info := range logs {
+		s.buf = append(s.buf, info.LogBuf...)
+	}
+
+	_, err := s.w.Write(s.buf)
+	return err
+}
+
+// Flush the writer if it has a Flush method, eg: bufio.Writer.
+func (s *WriterLogSink) Flush() error {
+	flusher, ok := s.w.(interface{ Flush() error })
+	if !ok {
+		return nil
+	}
+
+	return flusher.Flush()
+}
+
+// Sync the writer if it has a Sync method, eg: os.File.
+func (s *WriterLogSink) Sync() error {
+	syncer, ok := s.w.(LogSyncer)
+	if !ok {
+		return nil
+	}
+
+	return syncer.Sync()
+}
+
+func (s *WriterLogSink) Close() error {
+	return s.Flush()
+}
@@ -0,0 +1,231 @@
+// Copyright 2022 Guan Jianchang. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package yx
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// A writer which can be read while the logger is writing.
+type testSyncBuffer struct {
+	lck     *sync.Mutex
+	buf     bytes.Buffer
+	syncCnt int
+}
+
+func newTestSyncBuffer() *testSyncBuffer {
+	return &testSyncBuffer{
+		lck:     &sync.Mutex{},
+		syncCnt: 0,
+	}
+}
+
+func (b *testSyncBuffer) Write(p []byte) (int, error) {
+	b.lck.Lock()
+	defer b.lck.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *testSyncBuffer) Sync() error {
+	b.lck.Lock()
+	defer b.lck.Unlock()
+
+	b.syncCnt++
+	return nil
+}
+
+func (b *testSyncBuffer) String() string {
+	b.lck.Lock()
+	defer b.lck.Unlock()
+
+	return b.buf.String()
+}
+
+func TestLogWriterCaller(t *testing.T) {
+	l, c := NewCaptureLogger("writer")
+	defer l.StopLogger()
+
+	w := l.NewLogWriter(LOG_LV_WARN, "writer")
+	lines := make([]int, 0)
+
+	_, _, line, _ := runtime.Caller(0)
+	fmt.Fprintln(w, "by fmt")
+	lines = append(lines, line+1)
+
+	_, _, line, _ = runtime.Caller(0)
+	io.WriteString(w, "by io\n")
+	lines = append(lines, line+1)
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("by bufio\n")
+	_, _, line, _ = runtime.Caller(0)
+	bw.Flush()
+	lines = append(lines, line+1)
+
+	_, _, line, _ = runtime.Caller(0)
+	w.Write([]byte("by write\n"))
+	lines = append(lines, line+1)
+
+	logs := c.Logs()
+	if len(logs) != len(lines) {
+		t.Fatalf("Logs() = %d logs, want %d", len(logs), len(lines))
+	}
+
+	for i, log := range logs {
+		want := "log_writer_test.go:" + strconv.Itoa(lines[i])
+		if filepath.Base(log.Caller) != want {
+			t.Errorf("caller of %q = %s, want %s", log.Msg, log.Caller, want)
+		}
+	}
+}
+
+func TestWriterLogSink(t *testing.T) {
+	var out bytes.Buffer
+	bw := bufio.NewWriter(&out)
+	sink := NewWriterLogSink(bw)
+
+	logs := []*LogInfo{{LogBuf: []byte("one\n")}, {LogBuf: []byte("two\n")}}
+	err := sink.WriteLogs(logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the logger reuses the buffers after WriteLogs return
+	copy(logs[0].LogBuf, "xxx\n")
+	logs[1].LogBuf = append(logs[1].LogBuf[:0], "three\n"...)
+	err = sink.WriteLogs(logs[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Len() != 0 {
+		t.Errorf("the bufio.Writer is flushed before Flush: %q", out.String())
+	}
+
+	err = sink.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "one\ntwo\nthree\n" {
+		t.Errorf("output = %q, want %q", out.String(), "one\ntwo\nthree\n")
+	}
+
+	// the writer without Flush and Sync
+	sink = NewWriterLogSink(&out)
+	if sink.Flush() != nil || sink.Sync() != nil || sink.Close() != nil {
+		t.Errorf("Flush, Sync or Close of a plain writer return error")
+	}
+}
+
+func TestWriterLogSinkWithLogger(t *testing.T) {
+	out := newTestSyncBuffer()
+	l := NewIndependentLogger("writer")
+	l.RemoveLogSink(LOG_SINK_NAME_CONSOLE)
+	l.AddLogSink("writer", NewWriterLogSink(out), LOG_LV_TRACE)
+	l.loggerImpl.SetLayout("{level}|{tag}|{msg}")
+	l.SetLogFsyncPolicy(LOG_FSYNC_FLUSH)
+	l.StartLogger()
+	defer l.StopLogger()
+
+	for i := 0; i < 3; i++ {
+		l.I("line", i)
+	}
+
+	l.FlushLogger()
+
+	want := "INFO|writer|line0\nINFO|writer|line1\nINFO|writer|line2\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+	out.lck.Lock()
+	syncCnt := out.syncCnt
+	out.lck.Unlock()
+	if syncCnt == 0 {
+		t.Errorf("the writer is not synced by the explicit flush")
+	}
+}
+
+func TestNewStdLogger(t *testing.T) {
+	l, c := NewCaptureLogger("std")
+	defer l.StopLogger()
+
+	stdLogger := l.NewStdLogger(LOG_LV_WARN, "http")
+	_, _, line, _ := runtime.Caller(0)
+	stdLogger.Printf("bad request %d\n", 400)
+	stdLogger.Print("first\nsecond")
+
+	debugLogger := l.NewStdLogger(LOG_LV_DEBUG, "http")
+	l.SetLogLevel(LOG_LV_INFO)
+	debugLogger.Print("filtered")
+
+	logs := c.Logs()
+	if len(logs) != 3 {
+		t.Fatalf("Logs() = %+v, want 3 logs", logs)
+	}
+
+	wantMsgs := []string{"bad request 400", "first", "second"}
+	for i, log := range logs {
+		if log.Lv != LOG_LV_WARN || log.Tag != "http" || log.Msg != wantMsgs[i] {
+			t.Errorf("logs[%d] = %+v, want %s [http] %q", i, log, "WARN", wantMsgs[i])
+		}
+	}
+
+	wantCaller := "log_writer_test.go:" + strconv.Itoa(line+1)
+	if filepath.Base(logs[0].Caller) != wantCaller {
+		t.Errorf("caller = %s, want %s", logs[0].Caller, wantCaller)
+	}
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	l, c := NewCaptureLogger("std")
+	defer l.StopLogger()
+
+	// restored at the end
+	oldOutput := log.Writer()
+	oldFlags := log.Flags()
+	defer log.SetFlags(oldFlags)
+	defer log.SetOutput(oldOutput)
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	log.SetFlags(log.LstdFlags)
+
+	restore := l.RedirectStdLog(LOG_LV_ERROR, "stdlog")
+	_, _, line, _ := runtime.Caller(0)
+	log.Println("redirected")
+	log.Print("no line break")
+	restore()
+	log.Print("after restore")
+
+	logs := c.Logs()
+	if len(logs) != 2 || logs[0].Msg != "redirected" || logs[1].Msg != "no line break" {
+		t.Fatalf("Logs() = %+v, want the redirected logs", logs)
+	}
+
+	if logs[0].Lv != LOG_LV_ERROR || logs[0].Tag != "stdlog" {
+		t.Errorf("logs[0] = %+v, want ERROR [stdlog]", logs[0])
+	}
+
+	wantCaller := "log_writer_test.go:" + strconv.Itoa(line+1)
+	if filepath.Base(logs[0].Caller) != wantCaller {
+		t.Errorf("caller = %s, want %s", logs[0].Caller, wantCaller)
+	}
+
+	if log.Flags() != log.LstdFlags || out.Len() == 0 || !bytes.Contains(out.Bytes(), []byte("after restore")) {
+		t.Errorf("the output or the flags are not restored, flags = %d, output = %q", log.Flags(), out.String())
+	}
+}
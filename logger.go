@@ -5,13 +5,13 @@
 package yx
 
 import (
-	"bufio"
 	"fmt"
+	"log"
 	"os"
-	"path"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 	// "syscall"
 )
@@ -24,6 +24,8 @@ const (
 	LOG_DEFAULT_DUMP_INTV      = 100
 
 	LOG_STR_BUILD_INIT_CAP = 128
+
+	LOG_JSON_TIME_FORMAT = "2006-01-02T15:04:05.000000Z07:00"
 )
 
 const LOG_DEBUG_SWITCH_FILE = "debug.sf"
@@ -31,17 +33,24 @@ const LOG_DEBUG_SWITCH_FILE = "debug.sf"
 type LogLv = int
 
 const (
+	LOG_LV_TRACE LogLv = -1
 	LOG_LV_DEBUG LogLv = 0
 	LOG_LV_INFO  LogLv = 1
 	LOG_LV_WARN  LogLv = 2
 	LOG_LV_ERROR LogLv = 3
+	LOG_LV_FATAL LogLv = 4
+)
+
+const (
+	LOG_DEFAULT_FATAL_EXIT_CODE = 1
+	LOG_FATAL_STACK_SIZE        = 1024 * 1024
 )
 
 //========================
 //    global method
 //========================
 func StartLogger() {
-	go loggerInst.loop()
+	loggerInst.start()
 }
 
 func StopLogger() {
@@ -54,13 +63,13 @@ func StopLogger() {
 // @param dumpThreshold, max count of logs in buffer to cause dump.
 // @param dumpIntervalMs, dump interval in millisecond.
 func StartDumpLog(file string, dumpFileSize int, dumpThreshold int, dumpIntervalMs uint32) {
-	loggerInst.startDump(file, dumpFileSize, dumpThreshold, dumpIntervalMs)
+	loggerInst.startDump(file, dumpFileSize, dumpThreshold, dumpIntervalMs, nil)
 }
 
 // Start dump log by default params.
 // @param file, the relative/full path of a file.
 func StartDumpLogDefault(file string) {
-	loggerInst.startDump(file, LOG_DEFAULT_DUMP_SIZE, LOG_DEFAULT_DUMP_THRESHOLD, LOG_DEFAULT_DUMP_INTV)
+	loggerInst.startDump(file, LOG_DEFAULT_DUMP_SIZE, LOG_DEFAULT_DUMP_THRESHOLD, LOG_DEFAULT_DUMP_INTV, nil)
 }
 
 // Stop dump log.
@@ -68,6 +77,47 @@ func StopDumpLog() {
 	loggerInst.stopDump()
 }
 
+// Watch the config file, the changes of it will be applied without restart.
+// @param path, path of the json file.
+// @param decodeCb, a callback function to decode the content of the file.
+func WatchLogConf(path string, decodeCb func(data []byte) ([]byte, error)) {
+	loggerInst.watchConf(path, decodeCb)
+}
+
+// Stop watch the config file.
+func StopWatchLogConf() {
+	loggerInst.stopWatchConf()
+}
+
+// Load the config file, config the logger and watch the file.
+// @param path, path of the json file.
+// @param decodeCb, a callback function to decode the content of the file.
+// @param printFunc, the print function of console.
+// @return error, error.
+func ConfigLoggerByFile(path string, decodeCb func(data []byte) ([]byte, error), printFunc func(lv LogLv, logStr string)) error {
+	return loggerInst.configByFile(path, decodeCb, printFunc)
+}
+
+// Set the capacity and the overflow policy of the log queue.
+// @param capacity, the max count of logs in queue, 0 mean no limit.
+// @param policy, LOG_OVERFLOW_XXX.
+// @param overflowLv, logs below the level will be dropped when the policy is LOG_OVERFLOW_DROP_BELOW_LEVEL.
+func SetLogQueue(capacity int, policy string, overflowLv LogLv) {
+	loggerInst.setQueueConf(capacity, policy, overflowLv)
+}
+
+// Get the count of the logs dropped by the overflow policy.
+// @return uint64, the count.
+func GetLogDropCount() uint64 {
+	return loggerInst.getDropCount()
+}
+
+// Set the exit code when print a fatal log.
+// @param code, the exit code.
+func SetFatalExitCode(code int) {
+	loggerInst.setFatalExitCode(code)
+}
+
 // Set log level.
 // @param lv, the level to begin print.
 func SetLogLevel(lv LogLv) {
@@ -78,10 +128,53 @@ func SetShowCaller(bShowCaller bool) {
 	loggerInst.SetShowCaller(bShowCaller)
 }
 
+// Set log level of the tags.
+// @param pattern, the pattern of the tags, '*' match any characters, eg: net.*
+// @param lv, the level to begin print.
+func SetTagLogLevel(pattern string, lv LogLv) {
+	loggerInst.tagLevels.setLevel(pattern, lv)
+}
+
+// Replace all the tag log levels.
+// @param rules, eg: "net.*=debug, db=warn".
+// @return error, error.
+func SetTagLogLevels(rules string) error {
+	return loggerInst.tagLevels.setRules(rules)
+}
+
+// Clear all the tag log levels.
+func ClearTagLogLevels() {
+	loggerInst.tagLevels.clear()
+}
+
+// Set the layout of text log line, empty mean the default layout.
+// @param pattern, the layout pattern, eg: "[{time:YY/MM/DD hh:mm:ss.SSS}] [{level}] [{tag}] {msg}".
+// @return error, error.
+func SetLogLayout(pattern string) error {
+	return loggerInst.SetLayout(pattern)
+}
+
+// Set json format mode.
+// @param bJsonFormat, true mean output one json object per line.
+func SetJsonFormat(bJsonFormat bool) {
+	loggerInst.SetJsonFormat(bJsonFormat)
+}
+
 func LogArgs(a ...interface{}) []interface{} {
 	return a
 }
 
+// Build a key/value field which can be passed to D/I/W/E as an argument.
+// @param key, the field key.
+// @param value, the field value.
+// @return LogField, the field.
+func LogKV(key string, value interface{}) LogField {
+	return LogField{
+		Key:   key,
+		Value: value,
+	}
+}
+
 // Set to PowerShell mode.
 // func SetPowerShellMode() {
 // 	loggerInst.SetPowerShellMode()
@@ -141,64 +234,206 @@ func SetPrintFunc(printFunc func(lv LogLv, logStr string)) {
 	loggerInst.SetPrintFunc(printFunc)
 }
 
+// Add a sink to output logs, the sink with the same name will be replaced.
+// @param name, the name of the sink.
+// @param sink, the sink.
+// @param minLv, the min level of logs to write to the sink.
+func AddLogSink(name string, sink LogSink, minLv LogLv) {
+	loggerInst.addSink(name, sink, minLv)
+}
+
+// Remove a sink.
+// @param name, the name of the sink.
+func RemoveLogSink(name string) {
+	loggerInst.removeSink(name)
+}
+
 //========================
 //    log config
 //========================
 type LogConf struct {
-	Level        int  `json:"level"`
-	IsShowCaller bool `json:"is_show_caller"`
+	Name           string `json:"name"` // the name in the registry, see GetLogger
+	Level          int    `json:"level"`
+	IsShowCaller   bool   `json:"is_show_caller"`
+	CallerSkip     int    `json:"caller_skip"`      // extra frames to skip for the wrapper libraries
+	CallerPathMode string `json:"caller_path_mode"` // full, short, relative
+	CallerRoot     string `json:"caller_root"`      // root directory of relative mode, empty mean the working directory
+	IsShowFunc     bool   `json:"is_show_func"`
+	IsErrorStack   bool   `json:"is_error_stack"`
 	// IsPowerShellRun bool   `json:"power_shell_run"`
 	IsDump        bool   `json:"is_dump"`
 	DumpPath      string `json:"dump_path"`
 	DumpFileSize  int    `json:"dump_file_size"`
 	DumpThreshold int    `json:"dump_threshold"`
 	DumpInterval  uint32 `json:"dump_interval"`
+	DumpBakPath   string `json:"dump_bak_path"`   // empty mean the name of the dump file with ".bak" in the working directory
+	DumpRetrySize int    `json:"dump_retry_size"` // max count of logs kept in memory when fail to dump
+	IsJsonFormat  bool   `json:"is_json_format"`
+	Layout        string `json:"layout"`     // eg: "[{time:YY/MM/DD hh:mm:ss.SSS}] [{level}] [{tag}] {msg}"
+	TagLevels     string `json:"tag_levels"` // eg: "net.*=debug, db=warn"
+	LogRotateConf
+	DumpSplits []*LogDumpSplit `json:"dump_splits"` // the level-split dump files, eg: "app.error.log" for WARN+
+
+	QueueCapacity      int    `json:"queue_capacity"`       // 0 mean no limit
+	OverflowPolicy     string `json:"overflow_policy"`      // block, drop_newest, drop_oldest, drop_below_level
+	OverflowLevel      int    `json:"overflow_level"`       // for drop_below_level
+	DropReportInterval uint32 `json:"drop_report_interval"` // millisecond
+	FsyncPolicy        string `json:"fsync_policy"`         // none, flush, always
+	FatalExitCode      int    `json:"fatal_exit_code"`      // 0 mean the default code 1
+	SampleInterval     uint32 `json:"sample_interval"`      // millisecond
+	SampleFirst        int    `json:"sample_first"`         // 0 mean no sampling
+	SampleThereafter   int    `json:"sample_thereafter"`    // 0 mean drop all after the first logs
+	IsDedupe           bool   `json:"is_dedupe"`
+	DedupeInterval     uint32 `json:"dedupe_interval"` // millisecond
+
+	// the secrets are masked before any sink see the logs
+	RedactFields   []string `json:"redact_fields"`   // eg: ["password", "*token*"], case insensitive
+	RedactPatterns []string `json:"redact_patterns"` // eg: ["token=(\\S+)"], only the groups are masked if any
+	RedactMask     string   `json:"redact_mask"`     // empty mean "******"
+
+	// if not empty, the sinks replace the default console/dump outputs.
+	Sinks []*LogSinkConf `json:"sinks"`
+
+	// the named independent loggers, only used by ConfigLoggers.
+	Loggers []*LogConf `json:"loggers"`
 }
 
 func ConfigLogger(cfg *LogConf, printFunc func(lv LogLv, logStr string)) {
-	SetLogLevel(cfg.Level)
-	SetShowCaller(cfg.IsShowCaller)
-	SetPrintFunc(printFunc)
-	// if cfg.IsPowerShellRun {
-	// 	SetPowerShellMode()
-	// }
+	loggerInst.config(cfg, printFunc)
+}
+
+//========================
+//       LogField
+//========================
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+// Build fields from key/value pairs.
+// @param kvs, key/value pairs, the key will be converted to string.
+// @return []LogField, the fields.
+func buildLogFields(kvs []interface{}) []LogField {
+	fields := make([]LogField, 0, (len(kvs)+1)/2)
+	for i := 0; i < len(kvs); i += 2 {
+		key, ok := kvs[i].(string)
+		if !ok {
+			key = fmt.Sprint(kvs[i])
+		}
+
+		var value interface{} = nil
+		if i+1 < len(kvs) {
+			value = kvs[i+1]
+		}
+
+		fields = append(fields, LogField{Key: key, Value: value})
+	}
+
+	return fields
+}
 
-	if cfg.IsDump {
-		StartDumpLog(cfg.DumpPath, cfg.DumpFileSize, cfg.DumpThreshold, cfg.DumpInterval)
+func mergeLogFields(fields []LogField, kvs []interface{}) []LogField {
+	newFields := buildLogFields(kvs)
+	if len(fields) == 0 {
+		return newFields
 	}
+
+	merged := make([]LogField, 0, len(fields)+len(newFields))
+	merged = append(merged, fields...)
+	merged = append(merged, newFields...)
+	return merged
 }
 
 //========================
 //        Logger
 //========================
 type Logger struct {
-	tag string
+	tag    string
+	fields []LogField
 }
 
 func NewLogger(tag string) *Logger {
 	return &Logger{
-		tag: tag,
+		tag:    tag,
+		fields: nil,
 	}
 }
 
+// Create a child logger which carry the fields.
+// @param kvs, key/value pairs.
+// @return *Logger, the child logger.
+func (l *Logger) With(kvs ...interface{}) *Logger {
+	return &Logger{
+		tag:    l.tag,
+		fields: mergeLogFields(l.fields, kvs),
+	}
+}
+
+// Print trace log.
+func (l *Logger) T(a ...interface{}) {
+	loggerInst.T(l.tag, l.fields, a...)
+}
+
 // Print debug log.
 func (l *Logger) D(a ...interface{}) {
-	loggerInst.D(l.tag, a...)
+	loggerInst.D(l.tag, l.fields, a...)
 }
 
 // Print infomation log.
 func (l *Logger) I(a ...interface{}) {
-	loggerInst.I(l.tag, a...)
+	loggerInst.I(l.tag, l.fields, a...)
 }
 
 // Print warn log.
 func (l *Logger) W(a ...interface{}) {
-	loggerInst.W(l.tag, a...)
+	loggerInst.W(l.tag, l.fields, a...)
 }
 
 // Print error log.
 func (l *Logger) E(a ...interface{}) {
-	loggerInst.E(l.tag, a...)
+	loggerInst.E(l.tag, l.fields, a...)
+}
+
+// Print fatal log with the stacks of all goroutines, flush all the loggers and exit.
+func (l *Logger) F(a ...interface{}) {
+	loggerInst.F(l.tag, l.fields, a...)
+}
+
+// Print trace log with format.
+func (l *Logger) Tf(format string, a ...interface{}) {
+	loggerInst.Tf(l.tag, l.fields, format, a...)
+}
+
+// Print debug log with format, the format is done by the writer goroutine.
+func (l *Logger) Df(format string, a ...interface{}) {
+	loggerInst.Df(l.tag, l.fields, format, a...)
+}
+
+// Print infomation log with format.
+func (l *Logger) If(format string, a ...interface{}) {
+	loggerInst.If(l.tag, l.fields, format, a...)
+}
+
+// Print warn log with format.
+func (l *Logger) Wf(format string, a ...interface{}) {
+	loggerInst.Wf(l.tag, l.fields, format, a...)
+}
+
+// Print error log with format.
+func (l *Logger) Ef(format string, a ...interface{}) {
+	loggerInst.Ef(l.tag, l.fields, format, a...)
+}
+
+// Print fatal log with format, then exit.
+func (l *Logger) Ff(format string, a ...interface{}) {
+	loggerInst.Ff(l.tag, l.fields, format, a...)
+}
+
+// Check the level is enabled, use to guard expensive argument construction.
+// @param lv, the level.
+// @return bool, true mean the log of the level will be printed.
+func (l *Logger) Enabled(lv LogLv) bool {
+	return loggerInst.isEnabled(l.tag, lv)
 }
 
 // Print detail log.
@@ -228,24 +463,84 @@ func NewIndependentLogger(tag string) *IndependentLogger {
 	return l
 }
 
+// Create a child logger which carry the fields, share the same logger implement.
+// @param kvs, key/value pairs.
+// @return *IndependentLogger, the child logger.
+func (l *IndependentLogger) With(kvs ...interface{}) *IndependentLogger {
+	child := &IndependentLogger{
+		loggerImpl: l.loggerImpl,
+	}
+
+	child.tag = l.tag
+	child.fields = mergeLogFields(l.fields, kvs)
+	return child
+}
+
+// Print trace log.
+func (l *IndependentLogger) T(a ...interface{}) {
+	l.loggerImpl.T(l.tag, l.fields, a...)
+}
+
 // Print debug log.
 func (l *IndependentLogger) D(a ...interface{}) {
-	l.loggerImpl.D(l.tag, a...)
+	l.loggerImpl.D(l.tag, l.fields, a...)
 }
 
 // Print infomation log.
 func (l *IndependentLogger) I(a ...interface{}) {
-	l.loggerImpl.I(l.tag, a...)
+	l.loggerImpl.I(l.tag, l.fields, a...)
 }
 
 // Print warn log.
 func (l *IndependentLogger) W(a ...interface{}) {
-	l.loggerImpl.W(l.tag, a...)
+	l.loggerImpl.W(l.tag, l.fields, a...)
 }
 
 // Print error log.
 func (l *IndependentLogger) E(a ...interface{}) {
-	l.loggerImpl.E(l.tag, a...)
+	l.loggerImpl.E(l.tag, l.fields, a...)
+}
+
+// Print fatal log with the stacks of all goroutines, flush all the loggers and exit.
+func (l *IndependentLogger) F(a ...interface{}) {
+	l.loggerImpl.F(l.tag, l.fields, a...)
+}
+
+// Print trace log with format.
+func (l *IndependentLogger) Tf(format string, a ...interface{}) {
+	l.loggerImpl.Tf(l.tag, l.fields, format, a...)
+}
+
+// Print debug log with format, the format is done by the writer goroutine.
+func (l *IndependentLogger) Df(format string, a ...interface{}) {
+	l.loggerImpl.Df(l.tag, l.fields, format, a...)
+}
+
+// Print infomation log with format.
+func (l *IndependentLogger) If(format string, a ...interface{}) {
+	l.loggerImpl.If(l.tag, l.fields, format, a...)
+}
+
+// Print warn log with format.
+func (l *IndependentLogger) Wf(format string, a ...interface{}) {
+	l.loggerImpl.Wf(l.tag, l.fields, format, a...)
+}
+
+// Print error log with format.
+func (l *IndependentLogger) Ef(format string, a ...interface{}) {
+	l.loggerImpl.Ef(l.tag, l.fields, format, a...)
+}
+
+// Print fatal log with format, then exit.
+func (l *IndependentLogger) Ff(format string, a ...interface{}) {
+	l.loggerImpl.Ff(l.tag, l.fields, format, a...)
+}
+
+// Check the level is enabled.
+// @param lv, the level.
+// @return bool, true mean the log of the level will be printed.
+func (l *IndependentLogger) Enabled(lv LogLv) bool {
+	return l.loggerImpl.isEnabled(l.tag, lv)
 }
 
 // Print detail log.
@@ -258,7 +553,64 @@ func (l *IndependentLogger) Ln() {
 }
 
 func (l *IndependentLogger) StartLogger() {
-	go l.loggerImpl.loop()
+	l.loggerImpl.start()
+}
+
+// Flush the logger, block until all the logs queued before the call are written.
+func (l *IndependentLogger) FlushLogger() {
+	l.loggerImpl.flush()
+}
+
+func (l *IndependentLogger) SetLogFsyncPolicy(policy string) {
+	l.loggerImpl.setFsyncPolicy(policy)
+}
+
+func (l *IndependentLogger) ReopenLogFiles() {
+	l.loggerImpl.reopenSinks()
+}
+
+func (l *IndependentLogger) WatchLogSignal() {
+	l.loggerImpl.watchSignal()
+}
+
+func (l *IndependentLogger) StopWatchLogSignal() {
+	l.loggerImpl.stopWatchSignal()
+}
+
+func (l *IndependentLogger) SetDumpBackup(bakFile string, retrySize int) {
+	l.loggerImpl.setDumpBackup(bakFile, retrySize)
+}
+
+func (l *IndependentLogger) SetDumpErrorCallback(cb func(file string, err error)) {
+	l.loggerImpl.setDumpErrorCallback(cb)
+}
+
+func (l *IndependentLogger) SetLogSampling(intervalMs uint32, first int, thereafter int) {
+	l.loggerImpl.sampler.setConf(intervalMs, first, thereafter)
+}
+
+func (l *IndependentLogger) SetLogDedupe(bDedupe bool, intervalMs uint32) {
+	l.loggerImpl.dedupe.setConf(bDedupe, intervalMs)
+}
+
+func (l *IndependentLogger) SetLogRedact(fields []string, patterns []string, mask string) error {
+	return l.loggerImpl.setRedact(fields, patterns, mask)
+}
+
+func (l *IndependentLogger) CaptureLogs() (*LogCapture, func()) {
+	return l.loggerImpl.captureLogs()
+}
+
+func (l *IndependentLogger) NewLogWriter(lv LogLv, tag string) *LogWriter {
+	return newLogWriter(l.loggerImpl, lv, tag, LOG_WRITER_AUTO_SKIP)
+}
+
+func (l *IndependentLogger) NewStdLogger(lv LogLv, tag string) *log.Logger {
+	return log.New(newLogWriter(l.loggerImpl, lv, tag, LOG_STD_CALLER_SKIP), "", 0)
+}
+
+func (l *IndependentLogger) RedirectStdLog(lv LogLv, tag string) func() {
+	return redirectStdLog(newLogWriter(l.loggerImpl, lv, tag, LOG_STD_CALLER_SKIP))
 }
 
 func (l *IndependentLogger) StopLogger() {
@@ -266,13 +618,21 @@ func (l *IndependentLogger) StopLogger() {
 }
 
 func (l *IndependentLogger) StartDumpLog(file string, dumpFileSize int, dumpThreshold int, dumpIntervalMs uint32) {
-	l.loggerImpl.startDump(file, dumpFileSize, dumpThreshold, dumpIntervalMs)
+	l.loggerImpl.startDump(file, dumpFileSize, dumpThreshold, dumpIntervalMs, nil)
 }
 
 // Start dump log by default params.
 // @param file, the relative/full path of a file.
 func (l *IndependentLogger) StartDumpLogDefault(file string) {
-	l.loggerImpl.startDump(file, LOG_DEFAULT_DUMP_SIZE, LOG_DEFAULT_DUMP_THRESHOLD, LOG_DEFAULT_DUMP_INTV)
+	l.loggerImpl.startDump(file, LOG_DEFAULT_DUMP_SIZE, LOG_DEFAULT_DUMP_THRESHOLD, LOG_DEFAULT_DUMP_INTV, nil)
+}
+
+func (l *IndependentLogger) AddLogDumpSplit(split *LogDumpSplit) error {
+	return l.loggerImpl.addDumpSplit(split)
+}
+
+func (l *IndependentLogger) RemoveLogDumpSplit(name string) {
+	l.loggerImpl.removeSink(LOG_SINK_NAME_DUMP_SPLIT_PREFIX + name)
 }
 
 // Stop dump log.
@@ -286,22 +646,92 @@ func (l *IndependentLogger) SetLogLevel(lv LogLv) {
 	l.loggerImpl.SetLevel(lv)
 }
 
+func (l *IndependentLogger) SetFatalExitCode(code int) {
+	l.loggerImpl.setFatalExitCode(code)
+}
+
 func (l *IndependentLogger) SetShowCaller(bShowCaller bool) {
 	l.loggerImpl.SetShowCaller(bShowCaller)
 }
 
+func (l *IndependentLogger) SetCallerSkip(skip int) {
+	l.loggerImpl.updateCallerConf(func(c *logCallerConf) {
+		c.skip = skip
+	})
+}
+
+func (l *IndependentLogger) SetCallerPathMode(mode string, root string) {
+	l.loggerImpl.updateCallerConf(func(c *logCallerConf) {
+		c.setPathMode(mode, root)
+	})
+}
+
+func (l *IndependentLogger) SetShowFunc(bShowFunc bool) {
+	l.loggerImpl.updateCallerConf(func(c *logCallerConf) {
+		c.bShowFunc = bShowFunc
+	})
+}
+
+func (l *IndependentLogger) SetErrorStack(bErrorStack bool) {
+	l.loggerImpl.updateCallerConf(func(c *logCallerConf) {
+		c.bErrorStack = bErrorStack
+	})
+}
+
+func (l *IndependentLogger) SetTagLogLevel(pattern string, lv LogLv) {
+	l.loggerImpl.tagLevels.setLevel(pattern, lv)
+}
+
+func (l *IndependentLogger) SetTagLogLevels(rules string) error {
+	return l.loggerImpl.tagLevels.setRules(rules)
+}
+
+func (l *IndependentLogger) ClearTagLogLevels() {
+	l.loggerImpl.tagLevels.clear()
+}
+
+func (l *IndependentLogger) SetLogQueue(capacity int, policy string, overflowLv LogLv) {
+	l.loggerImpl.setQueueConf(capacity, policy, overflowLv)
+}
+
+func (l *IndependentLogger) GetLogDropCount() uint64 {
+	return l.loggerImpl.getDropCount()
+}
+
 func (l *IndependentLogger) SetPrintFunc(printFunc func(lv LogLv, logStr string)) {
 	l.loggerImpl.SetPrintFunc(printFunc)
 }
 
+func (l *IndependentLogger) SetJsonFormat(bJsonFormat bool) {
+	l.loggerImpl.SetJsonFormat(bJsonFormat)
+}
+
+func (l *IndependentLogger) SetLogLayout(pattern string) error {
+	return l.loggerImpl.SetLayout(pattern)
+}
+
 func (l *IndependentLogger) ConfigLogger(cfg *LogConf, printFunc func(lv LogLv, logStr string)) {
-	l.SetLogLevel(cfg.Level)
-	l.SetShowCaller(cfg.IsShowCaller)
-	l.SetPrintFunc(printFunc)
+	l.loggerImpl.config(cfg, printFunc)
+}
 
-	if cfg.IsDump {
-		l.StartDumpLog(cfg.DumpPath, cfg.DumpFileSize, cfg.DumpThreshold, cfg.DumpInterval)
-	}
+func (l *IndependentLogger) ConfigLoggerByFile(path string, decodeCb func(data []byte) ([]byte, error), printFunc func(lv LogLv, logStr string)) error {
+	return l.loggerImpl.configByFile(path, decodeCb, printFunc)
+}
+
+func (l *IndependentLogger) WatchLogConf(path string, decodeCb func(data []byte) ([]byte, error)) {
+	l.loggerImpl.watchConf(path, decodeCb)
+}
+
+func (l *IndependentLogger) StopWatchLogConf() {
+	l.loggerImpl.stopWatchConf()
+}
+
+func (l *IndependentLogger) AddLogSink(name string, sink LogSink, minLv LogLv) {
+	l.loggerImpl.addSink(name, sink, minLv)
+}
+
+func (l *IndependentLogger) RemoveLogSink(name string) {
+	l.loggerImpl.removeSink(name)
 }
 
 // // Print ln.
@@ -313,65 +743,214 @@ func (l *IndependentLogger) ConfigLogger(cfg *LogConf, printFunc func(lv LogLv,
 //                   logger
 //==============================================
 type LogInfo struct {
+	Time     time.Time
 	Lv       LogLv
 	Tag      string
+	Caller   string
+	Func     string
+	Gid      uint64
+	Format   string // printf format of Args, empty mean print Args by fmt.Sprint
 	Args     []interface{}
+	Fields   []LogField
 	IsDetail bool
-	// LogStr string
+	Stack    string
+	LogBuf   []byte // the formatted log, it is reused after LogSink.WriteLogs return
+
+	argBuf   []interface{}
+	buffRef  *[]byte
+	flushEvt *Event
+}
+
+// Get a log from the pool, the args are copied.
+func newLogInfo(lv LogLv, tag string, fields []LogField, logArgs []interface{}, bDetail bool) *LogInfo {
+	info := createLogInfo()
+	info.Time = time.Now()
+	info.Lv = lv
+	info.Tag = tag
+	info.Caller = ""
+	info.Func = ""
+	info.Gid = 0
+	info.Format = ""
+	info.argBuf = append(info.argBuf[:0], logArgs...)
+	info.Args = info.argBuf
+	info.Fields = fields
+	info.IsDetail = bDetail
+	info.Stack = ""
+	info.LogBuf = nil
+	info.buffRef = nil
+	info.flushEvt = nil
+	return info
 }
 
 type logger struct {
-	level          LogLv
-	bShowCaller    bool
-	bDebugSwitchOn bool
-	printFunc      func(lv LogLv, logStr string)
-	bDumpOpen      bool
-	strDumpFile    string
-	dumpFileSno    uint64
-	dumpFileSize   int
-	dumpThreshold  int
+	level          int32 // LogLv
+	tagLevels      *logTagLevels
+	bShowCaller    int32
+	bJsonFormat    int32
+	layout         atomic.Value // *logLayout
+	bDebugSwitchOn int32
+	bDumpOpen      int32
+	bRunning       int32  // 0 stopped, 1 running, 2 stopping
+	writerGid      uint64 // the goroutine id of the writer, 0 mean not running
+	bFlushPending  int32
+	fsyncPolicy    atomic.Value // string
+	fatalExitCode  int32
+	sampler        *logSampler
+	dedupe         *logDedupe
+	lckDumpConf    *FastLock
+	dumpConf       atomic.Value // *logDumpConf
+	dumpThreshold  int32
 	dumpIntervalMs uint32
+	dumpErrCb      atomic.Value // func(file string, err error)
 	// queLogs         chan string
 	// lck           *sync.Mutex
 	lck           *FastLock
 	queLogs       []*LogInfo
+	queHead       int
+	queConf       *logQueueConf
+	dropCnt       uint64
 	writeLogs     []*LogInfo
+	evtQueNotFull *Event
 	evtDumpToFile *Event
 	evtStop       *Event
 	evtStopSucc   *Event
+
+	consoleSink  *ConsoleLogSink
+	lckSinks     *FastLock
+	sinks        []*logSinkEntry
+	removedSinks []*logSinkEntry
+	filterLogs   []*LogInfo
+	msgBuf       *logBuffer
+	splitArgs    []interface{}
+	splitFields  []LogField
+	redactor     atomic.Value // *logRedactor
+	redactArgs   []interface{}
+	redactBuf    *logBuffer
+
+	curConf       atomic.Value // *LogConf
+	confWatcher   atomic.Value // *logConfWatcher
+	namedConf     atomic.Value // *LogConf, the config of the named logger pushed by the watcher of the global logger
+	lastNamedConf *LogConf
+	lckSignal     *FastLock
+	chanSignal    chan os.Signal
+
+	lckCallerConf *FastLock
+	callerConf    atomic.Value // *logCallerConf
 }
 
 var loggerInst = newLoggerImpl()
 
 func newLoggerImpl() *logger {
-	return &logger{
-		level:          LOG_LV_DEBUG,
-		bShowCaller:    false,
-		bDebugSwitchOn: false,
-		printFunc:      nil,
-		bDumpOpen:      false,
-		strDumpFile:    "",
-		dumpFileSno:    0,
-		dumpFileSize:   LOG_DEFAULT_DUMP_SIZE,
+	l := &logger{
+		level:          int32(LOG_LV_DEBUG),
+		tagLevels:      newLogTagLevels(),
+		bShowCaller:    0,
+		bJsonFormat:    0,
+		bDebugSwitchOn: 0,
+		bDumpOpen:      0,
+		bRunning:       0,
+		writerGid:      0,
+		bFlushPending:  0,
+		fatalExitCode:  LOG_DEFAULT_FATAL_EXIT_CODE,
+		sampler:        newLogSampler(),
+		dedupe:         newLogDedupe(),
+		lckDumpConf:    NewFastLock(),
 		dumpThreshold:  LOG_DEFAULT_DUMP_THRESHOLD,
 		dumpIntervalMs: LOG_DEFAULT_DUMP_INTV,
 		// queLogs:         make(chan string, MAX_LOG_CACHE_SIZE),
 		// lck:           &sync.Mutex{},
 		lck:           NewFastLock(),
 		queLogs:       nil,
+		queHead:       0,
+		queConf:       newLogQueueConf(),
+		dropCnt:       0,
 		writeLogs:     nil,
+		evtQueNotFull: NewEvent(),
 		evtDumpToFile: NewEvent(),
 		evtStop:       NewEvent(),
 		evtStopSucc:   NewEvent(),
+
+		consoleSink:  NewConsoleLogSink(nil),
+		lckSinks:     NewFastLock(),
+		sinks:        nil,
+		removedSinks: nil,
+		filterLogs:   nil,
+		msgBuf:       newLogBuffer(),
+		splitArgs:    nil,
+		splitFields:  nil,
+		redactArgs:   nil,
+		redactBuf:    newLogBuffer(),
+
+		lastNamedConf: nil,
+		lckSignal:     NewFastLock(),
+		chanSignal:    nil,
+
+		lckCallerConf: NewFastLock(),
 	}
+
+	l.layout.Store((*logLayout)(nil))
+	l.fsyncPolicy.Store(LOG_FSYNC_NONE)
+	l.dumpConf.Store(newLogDumpConf())
+	l.callerConf.Store(newLogCallerConf())
+	l.redactor.Store((*logRedactor)(nil))
+	l.curConf.Store((*LogConf)(nil))
+
+	l.confWatcher.Store((*logConfWatcher)(nil))
+	l.namedConf.Store((*LogConf)(nil))
+
+	l.sinks = []*logSinkEntry{newLogSinkEntry(LOG_SINK_NAME_CONSOLE, l.consoleSink, LOG_LV_TRACE)}
+	return l
 }
 
 func (l *logger) SetLevel(lv LogLv) {
-	l.level = lv
+	atomic.StoreInt32(&l.level, int32(lv))
+}
+
+func (l *logger) getLevel() LogLv {
+	return LogLv(atomic.LoadInt32(&l.level))
 }
 
 func (l *logger) SetShowCaller(bShowCaller bool) {
-	l.bShowCaller = bShowCaller
+	if bShowCaller {
+		atomic.StoreInt32(&l.bShowCaller, 1)
+	} else {
+		atomic.StoreInt32(&l.bShowCaller, 0)
+	}
+}
+
+func (l *logger) isShowCaller() bool {
+	return atomic.LoadInt32(&l.bShowCaller) == 1
+}
+
+func (l *logger) SetJsonFormat(bJsonFormat bool) {
+	if bJsonFormat {
+		atomic.StoreInt32(&l.bJsonFormat, 1)
+	} else {
+		atomic.StoreInt32(&l.bJsonFormat, 0)
+	}
+}
+
+func (l *logger) isJsonFormat() bool {
+	return atomic.LoadInt32(&l.bJsonFormat) == 1
+}
+
+func (l *logger) SetLayout(pattern string) error {
+	if len(pattern) == 0 {
+		l.layout.Store((*logLayout)(nil))
+		return nil
+	}
+
+	layout, err := compileLogLayout(pattern)
+	if err != nil {
+		return err
+	}
+
+	l.layout.Store(layout)
+	return nil
+}
+
+func (l *logger) getLayout() *logLayout {
+	return l.layout.Load().(*logLayout)
 }
 
 // func (l *logger) SetPowerShellMode() {
@@ -379,49 +958,325 @@ func (l *logger) SetShowCaller(bShowCaller bool) {
 // }
 
 func (l *logger) SetPrintFunc(printFunc func(lv LogLv, logStr string)) {
-	l.printFunc = printFunc
+	l.consoleSink.SetPrintFunc(printFunc)
+}
+
+func (l *logger) config(cfg *LogConf, printFunc func(lv LogLv, logStr string)) {
+	l.applyConf(cfg)
+	l.SetPrintFunc(printFunc)
+
+	// if cfg.IsPowerShellRun {
+	// 	l.SetPowerShellMode()
+	// }
+
+	if len(cfg.Sinks) > 0 {
+		l.configSinks(cfg)
+	} else if cfg.IsDump {
+		l.startDump(cfg.DumpPath, cfg.DumpFileSize, cfg.DumpThreshold, cfg.DumpInterval, &cfg.LogRotateConf)
+		l.setDumpSplits(cfg.DumpSplits)
+	}
+
+	l.curConf.Store(cfg)
+}
+
+// Apply the settings of the config, except the sinks and the print function.
+// @param cfg, the config.
+func (l *logger) applyConf(cfg *LogConf) {
+	l.SetLevel(cfg.Level)
+	err := l.tagLevels.setRules(cfg.TagLevels)
+	if err != nil {
+		fmt.Println("set tag log levels error: ", err)
+	}
+
+	l.SetShowCaller(cfg.IsShowCaller)
+	l.configCaller(cfg)
+	l.SetJsonFormat(cfg.IsJsonFormat)
+	err = l.SetLayout(cfg.Layout)
+	if err != nil {
+		fmt.Println("set log layout error: ", err)
+	}
+
+	l.setQueueConf(cfg.QueueCapacity, cfg.OverflowPolicy, cfg.OverflowLevel)
+	l.setDropReportInterval(cfg.DropReportInterval)
+	l.setFsyncPolicy(cfg.FsyncPolicy)
+	l.setFatalExitCode(cfg.FatalExitCode)
+	l.sampler.setConf(cfg.SampleInterval, cfg.SampleFirst, cfg.SampleThereafter)
+	l.dedupe.setConf(cfg.IsDedupe, cfg.DedupeInterval)
+	l.setDumpBackup(cfg.DumpBakPath, cfg.DumpRetrySize)
+	err = l.setRedact(cfg.RedactFields, cfg.RedactPatterns, cfg.RedactMask)
+	if err != nil {
+		fmt.Println("set log redact error: ", err)
+	}
+}
+
+func (l *logger) configSinks(cfg *LogConf) {
+	entries := make([]*logSinkEntry, 0, len(cfg.Sinks))
+	bHasFileSink := false
+	for i, sinkCfg := range cfg.Sinks {
+		sink, err := l.createSink(sinkCfg)
+		if err != nil {
+			fmt.Println("create log sink ", sinkCfg.Type, " error: ", err)
+			continue
+		}
+
+		name := sinkCfg.Name
+		if len(name) == 0 {
+			name = sinkCfg.Type + "_" + strconv.Itoa(i)
+		}
+
+		entries = append(entries, newLogSinkEntry(name, sink, sinkCfg.GetLevel()))
+		if sinkCfg.Type != LOG_SINK_TYPE_CONSOLE {
+			bHasFileSink = true
+		}
+	}
+
+	if bHasFileSink {
+		l.setDumpParams(cfg.DumpThreshold, cfg.DumpInterval)
+	}
+
+	l.setDumpOpen(bHasFileSink)
+
+	l.setSinks(entries)
+}
+
+func (l *logger) createSink(cfg *LogSinkConf) (LogSink, error) {
+	switch cfg.Type {
+	case LOG_SINK_TYPE_CONSOLE:
+		return l.consoleSink, nil
+
+	case LOG_SINK_TYPE_FILE:
+		sink := NewFileLogSink(cfg.Path, cfg.FileSize)
+		sink.SetRotateConf(&cfg.LogRotateConf)
+		sink.SetBackup(cfg.BakPath, cfg.RetrySize)
+		sink.SetErrorCallback(l.onDumpError)
+		return sink, nil
+
+	case LOG_SINK_TYPE_MAIL:
+		if cfg.Mail == nil {
+			return nil, ErrLogMailConfIsNil
+		}
+
+		return NewMailLogSink(cfg.Mail), nil
+
+	case LOG_SINK_TYPE_NET:
+		if cfg.Net == nil {
+			return nil, ErrLogNetConfIsNil
+		}
+
+		return NewNetLogSink(cfg.Net)
+
+	case LOG_SINK_TYPE_SYSLOG:
+		if cfg.Syslog == nil {
+			return nil, ErrLogSyslogConfIsNil
+		}
+
+		return NewSyslogLogSink(cfg.Syslog)
+
+	default:
+		builder, ok := getLogSinkBuilder(cfg.Type)
+		if !ok {
+			return nil, ErrLogSinkTypeNotExist
+		}
+
+		return builder(cfg)
+	}
+}
+
+func (l *logger) addSink(name string, sink LogSink, minLv LogLv) {
+	if l.lckSinks.TryLock(0) != nil {
+		return
+	}
+
+	defer l.lckSinks.Unlock()
+
+	sinks := make([]*logSinkEntry, 0, len(l.sinks)+1)
+	for _, entry := range l.sinks {
+		if entry.name == name {
+			l.removedSinks = append(l.removedSinks, entry)
+		} else {
+			sinks = append(sinks, entry)
+		}
+	}
+
+	l.sinks = append(sinks, newLogSinkEntry(name, sink, minLv))
+}
+
+func (l *logger) removeSink(name string) {
+	if l.lckSinks.TryLock(0) != nil {
+		return
+	}
+
+	defer l.lckSinks.Unlock()
+
+	sinks := make([]*logSinkEntry, 0, len(l.sinks))
+	for _, entry := range l.sinks {
+		if entry.name == name {
+			l.removedSinks = append(l.removedSinks, entry)
+		} else {
+			sinks = append(sinks, entry)
+		}
+	}
+
+	l.sinks = sinks
+}
+
+func (l *logger) setSinks(entries []*logSinkEntry) {
+	if l.lckSinks.TryLock(0) != nil {
+		return
+	}
+
+	defer l.lckSinks.Unlock()
+
+	l.removedSinks = append(l.removedSinks, l.sinks...)
+	l.sinks = entries
+}
+
+// Get the current sinks and the removed sinks which wait to close.
+// @return []*logSinkEntry, the current sinks.
+// @return []*logSinkEntry, the removed sinks.
+func (l *logger) getSinks() ([]*logSinkEntry, []*logSinkEntry) {
+	if l.lckSinks.TryLock(0) != nil {
+		return nil, nil
+	}
+
+	defer l.lckSinks.Unlock()
+
+	removedSinks := l.removedSinks
+	l.removedSinks = nil
+	return l.sinks, removedSinks
 }
 
-func (l *logger) D(tag string, a ...interface{}) {
+// Check the level of the tag is enabled.
+// @param tag, the tag.
+// @param lv, the level.
+// @return bool, true mean the log will be printed.
+func (l *logger) isEnabled(tag string, lv LogLv) bool {
+	if lv >= LOG_LV_ERROR {
+		return true
+	}
+
 	// bExist, _ := IsFileExist(LOG_DEBUG_SWITCH_FILE)
-	if !l.bDebugSwitchOn && l.level > LOG_LV_DEBUG {
+	if lv == LOG_LV_DEBUG && atomic.LoadInt32(&l.bDebugSwitchOn) == 1 {
+		return true
+	}
+
+	return l.tagLevels.getLevel(tag, l.getLevel()) <= lv
+}
+
+func (l *logger) setFatalExitCode(code int) {
+	if code == 0 {
+		code = LOG_DEFAULT_FATAL_EXIT_CODE
+	}
+
+	atomic.StoreInt32(&l.fatalExitCode, int32(code))
+}
+
+func (l *logger) T(tag string, fields []LogField, a ...interface{}) {
+	if !l.isEnabled(tag, LOG_LV_TRACE) {
+		return
+	}
+
+	l.printLog(LOG_LV_TRACE, tag, fields, "", a, false)
+}
+
+func (l *logger) D(tag string, fields []LogField, a ...interface{}) {
+	if !l.isEnabled(tag, LOG_LV_DEBUG) {
 		return
 	}
 
 	// l.doLog(LOG_LV_DEBUG, "DEBUG", tag, a...)
-	l.printLog(LOG_LV_DEBUG, tag, a, false)
+	l.printLog(LOG_LV_DEBUG, tag, fields, "", a, false)
 }
 
-func (l *logger) I(tag string, a ...interface{}) {
-	if l.level > LOG_LV_INFO {
+func (l *logger) I(tag string, fields []LogField, a ...interface{}) {
+	if !l.isEnabled(tag, LOG_LV_INFO) {
 		return
 	}
 
 	// l.doLog(LOG_LV_INFO, "INFO ", tag, a...)
-	l.printLog(LOG_LV_INFO, tag, a, false)
+	l.printLog(LOG_LV_INFO, tag, fields, "", a, false)
 }
 
-func (l *logger) W(tag string, a ...interface{}) {
-	if l.level > LOG_LV_WARN {
+func (l *logger) W(tag string, fields []LogField, a ...interface{}) {
+	if !l.isEnabled(tag, LOG_LV_WARN) {
 		return
 	}
 
 	// l.doLog(LOG_LV_WARN, "WARN ", tag, a...)
-	l.printLog(LOG_LV_WARN, tag, a, false)
+	l.printLog(LOG_LV_WARN, tag, fields, "", a, false)
 }
 
-func (l *logger) E(tag string, a ...interface{}) {
+func (l *logger) E(tag string, fields []LogField, a ...interface{}) {
 	// l.doLog(LOG_LV_ERROR, "ERROR", tag, a...)
-	l.printLog(LOG_LV_ERROR, tag, a, false)
+	l.printLog(LOG_LV_ERROR, tag, fields, "", a, false)
+}
+
+func (l *logger) F(tag string, fields []LogField, a ...interface{}) {
+	l.printLog(LOG_LV_FATAL, tag, fields, "", a, false)
+	l.fatalExit()
+}
+
+func (l *logger) Tf(tag string, fields []LogField, format string, a ...interface{}) {
+	if !l.isEnabled(tag, LOG_LV_TRACE) {
+		return
+	}
+
+	l.printLog(LOG_LV_TRACE, tag, fields, format, a, false)
+}
+
+func (l *logger) Df(tag string, fields []LogField, format string, a ...interface{}) {
+	if !l.isEnabled(tag, LOG_LV_DEBUG) {
+		return
+	}
+
+	l.printLog(LOG_LV_DEBUG, tag, fields, format, a, false)
+}
+
+func (l *logger) If(tag string, fields []LogField, format string, a ...interface{}) {
+	if !l.isEnabled(tag, LOG_LV_INFO) {
+		return
+	}
+
+	l.printLog(LOG_LV_INFO, tag, fields, format, a, false)
+}
+
+func (l *logger) Wf(tag string, fields []LogField, format string, a ...interface{}) {
+	if !l.isEnabled(tag, LOG_LV_WARN) {
+		return
+	}
+
+	l.printLog(LOG_LV_WARN, tag, fields, format, a, false)
+}
+
+func (l *logger) Ef(tag string, fields []LogField, format string, a ...interface{}) {
+	l.printLog(LOG_LV_ERROR, tag, fields, format, a, false)
+}
+
+func (l *logger) Ff(tag string, fields []LogField, format string, a ...interface{}) {
+	l.printLog(LOG_LV_FATAL, tag, fields, format, a, false)
+	l.fatalExit()
+}
+
+// Flush all the loggers and exit with the fatal exit code.
+func (l *logger) fatalExit() {
+	// not started or stopped, write the FATAL log in the current goroutine
+	if atomic.CompareAndSwapInt32(&l.bRunning, 0, 1) {
+		l.writeToSinks()
+		l.closeSinks()
+		atomic.StoreInt32(&l.bRunning, 0)
+	}
+
+	FlushAllLoggers()
+	os.Exit(int(atomic.LoadInt32(&l.fatalExitCode)))
 }
 
 func (l *logger) Ln() {
 	// l.printLog(LOG_LV_INFO, "\n")
-	l.printLog(LOG_LV_INFO, "", nil, true)
+	l.printLog(LOG_LV_INFO, "", nil, "", nil, true)
 }
 
 func (l *logger) Detail(lv LogLv, logs [][]interface{}) {
-	if l.level > lv {
+	if l.getLevel() > lv {
 		return
 	}
 
@@ -448,17 +1303,54 @@ func (l *logger) Detail(lv LogLv, logs [][]interface{}) {
 // 	// l.printLog(lv, logStr)
 // }
 
-func (l *logger) printLog(lv LogLv, tag string, logArgs []interface{}, bDetail bool) {
-	if l.bShowCaller || lv == LOG_LV_WARN || lv == LOG_LV_ERROR {
-		_, file, line, _ := runtime.Caller(3)
-		callerInfos := []interface{}{"[", file, ":", strconv.Itoa(line), "]  "}
-		logArgs = append(callerInfos, logArgs...)
-		tag = ""
+func (l *logger) printLog(lv LogLv, tag string, fields []LogField, format string, logArgs []interface{}, bDetail bool) {
+	l.printLogSkip(1, lv, tag, fields, format, logArgs, bDetail)
+}
+
+// Print the log, the caller is found by skipping the frames.
+// @param skip, the count of extra frames to skip, 0 mean the user code calls printLogSkip
+//        through two methods, eg: user code -> Logger.I -> logger.I -> printLogSkip.
+func (l *logger) printLogSkip(skip int, lv LogLv, tag string, fields []LogField, format string, logArgs []interface{}, bDetail bool) {
+	callerConf := l.getCallerConf()
+	layout := l.getLayout()
+	bLayoutCaller := (layout != nil && layout.bNeedCaller)
+	bNeedCaller := (l.isShowCaller() || lv >= LOG_LV_WARN || bLayoutCaller || callerConf.bShowFunc)
+	bSample := (!bDetail && lv < LOG_LV_FATAL && l.sampler.isOpen())
+
+	var pc uintptr = 0
+	if bNeedCaller || bSample {
+		pc = l.getCallerPc(callerConf, skip)
+	}
+
+	if bSample && !l.sampler.allow(lv, tag, pc, callerConf) {
+		return
+	}
+
+	info := newLogInfo(lv, tag, fields, logArgs, bDetail)
+	info.Format = format
+
+	if bNeedCaller {
+		caller := callerConf.getCaller(pc)
+		info.Caller = caller.caller
+		if callerConf.bShowFunc || (layout != nil && layout.bNeedFunc) {
+			info.Func = caller.funcName
+		}
+	}
+
+	if layout != nil && layout.bNeedGid {
+		info.Gid = getGoroutineId()
+	}
+
+	if lv == LOG_LV_FATAL {
+		buf := make([]byte, LOG_FATAL_STACK_SIZE)
+		info.Stack = string(buf[:runtime.Stack(buf, true)])
+	} else if lv == LOG_LV_ERROR && callerConf.bErrorStack {
+		info.Stack = getLogStack()
 	}
 
-	l.pushLog(lv, tag, logArgs, bDetail)
+	l.pushLog(info)
 
-	if l.bDumpOpen && l.needDump() {
+	if l.isDumpOpen() && l.needDump() {
 		l.evtDumpToFile.Broadcast()
 	}
 }
@@ -466,20 +1358,42 @@ func (l *logger) printLog(lv LogLv, tag string, logArgs []interface{}, bDetail b
 func (l *logger) printLogs(lv LogLv, tag string, logs [][]interface{}, bDetail bool) {
 	l.pushLogs(lv, tag, logs, bDetail)
 
-	if l.bDumpOpen && l.needDump() {
+	if l.isDumpOpen() && l.needDump() {
 		l.evtDumpToFile.Broadcast()
 	}
 }
 
-func (l *logger) pushLog(lv LogLv, tag string, logArgs []interface{}, bDetail bool) {
-	// l.lck.Lock()
-	if l.lck.TryLock(0) != nil {
-		return
-	}
+func (l *logger) pushLog(info *LogInfo) {
+	for {
+		// l.lck.Lock()
+		if l.lck.TryLock(0) != nil {
+			return
+		}
 
-	defer l.lck.Unlock()
+		bSucc := l.tryPushOneLog(info, true)
+		l.lck.Unlock()
+
+		if bSucc {
+			break
+		}
+
+		// the writer can't wait itself, eg: a sink callback log, exceed the capacity
+		if l.isWriterGoroutine() {
+			if l.lck.TryLock(0) != nil {
+				return
+			}
+
+			l.pushOneLog(info)
+			l.lck.Unlock()
+			break
+		}
 
-	l.pushOneLog(lv, tag, logArgs, bDetail)
+		if !l.waitQueueNotFull() {
+			atomic.AddUint64(&l.dropCnt, 1)
+			releaseLogInfo(info)
+			break
+		}
+	}
 }
 
 func (l *logger) pushLogs(lv LogLv, tag string, logs [][]interface{}, bDetail bool) {
@@ -488,21 +1402,24 @@ func (l *logger) pushLogs(lv LogLv, tag string, logs [][]interface{}, bDetail bo
 		return
 	}
 
+	// the policy is changed in lock
+	if l.queConf.policy == LOG_OVERFLOW_BLOCK {
+		l.lck.Unlock()
+		for _, log := range logs {
+			l.pushLog(newLogInfo(lv, tag, nil, log, bDetail))
+		}
+
+		return
+	}
+
 	defer l.lck.Unlock()
 
 	for _, log := range logs {
-		l.pushOneLog(lv, tag, log, bDetail)
+		l.tryPushOneLog(newLogInfo(lv, tag, nil, log, bDetail), false)
 	}
 }
 
-func (l *logger) pushOneLog(lv LogLv, tag string, logArgs []interface{}, bDetail bool) {
-	info := &LogInfo{
-		Lv:       lv,
-		Tag:      tag,
-		Args:     logArgs,
-		IsDetail: bDetail,
-	}
-
+func (l *logger) pushOneLog(info *LogInfo) {
 	l.queLogs = append(l.queLogs, info)
 }
 
@@ -514,7 +1431,9 @@ func (l *logger) popLogs() {
 
 	defer l.lck.Unlock()
 
+	l.compactQueue()
 	l.queLogs, l.writeLogs = l.writeLogs, l.queLogs
+	l.evtQueNotFull.Broadcast()
 	// logs := make([]string, len(l.queLogs))
 	// copy(logs, l.queLogs)
 	// l.queLogs = l.queLogs[0:0]
@@ -522,29 +1441,107 @@ func (l *logger) popLogs() {
 }
 
 func (l *logger) loop() {
+	atomic.StoreUint64(&l.writerGid, getGoroutineId())
+	defer atomic.StoreUint64(&l.writerGid, 0)
+
 	for {
 		bEnd := false
-		if !l.bDumpOpen {
+		if !l.isDumpOpen() {
 			bEnd = l.isStop()
-			l.printConsoleLogs()
+			if !l.writeToSinks() {
+				<-time.After(time.Millisecond * 10)
+			}
 		} else {
-			l.bDebugSwitchOn, _ = IsFileExist(LOG_DEBUG_SWITCH_FILE)
-			l.evtDumpToFile.WaitUntilTimeout(l.dumpIntervalMs)
+			l.checkDebugSwitch()
+			if atomic.SwapInt32(&l.bFlushPending, 0) == 0 {
+				l.evtDumpToFile.WaitUntilTimeout(atomic.LoadUint32(&l.dumpIntervalMs))
+			}
+
 			bEnd = l.isStop() // judge end first, ensure dump all logs before stop dump
-			l.dump()
+			l.writeToSinks()
 		}
 
+		l.checkConf()
+
 		if bEnd {
+			l.closeSinks()
+			removeRunningLogger(l)
 			l.evtStopSucc.Close()
 			break
 		}
 	}
 }
 
+// Write the queue logs to all the sinks.
+// @return bool, true mean some logs have been written.
+func (l *logger) writeToSinks() bool {
+	l.popLogs()
+	l.appendDropReport()
+	l.appendSampleReport()
+	l.collapseDupLogs()
+	sinks, removedSinks := l.getSinks()
+	l.closeSinkEntries(removedSinks)
+
+	if len(l.writeLogs) == 0 {
+		return false
+	}
+
+	flushEvts := l.takeFlushEvents()
+	for _, info := range l.writeLogs {
+		l.redactLog(info)
+		info.LogBuf = l.buildLogStr(info)
+	}
+
+	for _, entry := range sinks {
+		l.filterLogs = entry.writeLogs(l.writeLogs, l.filterLogs)
+	}
+
+	l.flushSinks(sinks, len(flushEvts) > 0)
+	for _, evt := range flushEvts {
+		evt.Close()
+	}
+
+	// the sinks have copied what they keep, reuse the logs
+	for i := range l.filterLogs {
+		l.filterLogs[i] = nil
+	}
+
+	releaseLogInfos(l.writeLogs)
+	l.writeLogs = l.writeLogs[0:0]
+	return true
+}
+
+func (l *logger) closeSinks() {
+	sinks, removedSinks := l.getSinks()
+	l.closeSinkEntries(removedSinks)
+	l.closeSinkEntries(sinks)
+}
+
+func (l *logger) closeSinkEntries(entries []*logSinkEntry) {
+	for _, entry := range entries {
+		entry.sink.Flush()
+		err := entry.sink.Close()
+		if err != nil {
+			fmt.Println("close log sink ", entry.name, " error: ", err)
+		}
+	}
+}
+
 func (l *logger) stop() {
+	// not running or stopping
+	if !atomic.CompareAndSwapInt32(&l.bRunning, 1, 2) {
+		return
+	}
+
 	l.evtStop.Close()
 	l.evtDumpToFile.Close()
 	l.evtStopSucc.Wait()
+
+	// the logger can be started again
+	l.evtStop.Reset()
+	l.evtDumpToFile.Reset()
+	l.evtStopSucc.Reset()
+	atomic.StoreInt32(&l.bRunning, 0)
 }
 
 func (l *logger) isStop() bool {
@@ -562,259 +1559,336 @@ func (l *logger) isStop() bool {
 	// return bEnd
 }
 
-func (l *logger) buildLogStr(info *LogInfo) string {
-	builder := &strings.Builder{}
-	builder.Grow(LOG_STR_BUILD_INIT_CAP)
+// Format the log into a pooled buffer, must be called in the writer goroutine.
+// @param info, the log.
+// @return []byte, the formatted log.
+func (l *logger) buildLogStr(info *LogInfo) []byte {
+	args, fields := info.Args, info.Fields
+	if len(info.Format) == 0 {
+		args, fields = l.splitLogArgs(info.Args, info.Fields)
+	}
+
+	buf := getLogInfoBuff(info)
+	if l.isJsonFormat() {
+		return l.buildJsonLogStr(info, args, fields, buf)
+	}
+
+	layout := l.getLayout()
+	if layout != nil && !info.IsDetail {
+		return l.buildLayoutLogStr(layout, info, args, fields, buf)
+	}
 
 	if !info.IsDetail {
 		// time
-		t := time.Now()
-		builder.WriteRune('[')
-		FormatTimeStr("YY/MM/DD hh:mm:ss", t, builder)
-		builder.WriteRune(']')
-		builder.WriteRune(' ')
+		buf = append(buf, '[')
+		buf = formatLogTime(logDefaultTimeParts, info.Time, buf)
+		buf = append(buf, ']', ' ')
 
 		// level
 		lvStr := l.getLvStr(info.Lv)
-		builder.WriteRune('[')
-		builder.WriteString(lvStr)
-		builder.WriteRune(']')
-		builder.WriteRune(' ')
+		buf = append(buf, '[')
+		buf = append(buf, lvStr...)
+		buf = append(buf, ']', ' ')
 
 		// tag
 		if len(info.Tag) > 0 {
-			builder.WriteRune('[')
-			builder.WriteString(info.Tag)
-			builder.WriteRune(']')
-			builder.WriteRune(' ')
-			builder.WriteRune(' ')
+			buf = append(buf, '[')
+			buf = append(buf, info.Tag...)
+			buf = append(buf, ']', ' ')
+		}
+
+		// caller
+		if len(info.Caller) > 0 {
+			buf = append(buf, '[')
+			buf = append(buf, info.Caller...)
+			if len(info.Func) > 0 {
+				buf = append(buf, ' ')
+				buf = append(buf, info.Func...)
+			}
+
+			buf = append(buf, ']', ' ')
+		}
+
+		if len(info.Tag) > 0 || len(info.Caller) > 0 {
+			buf = append(buf, ' ')
 		}
 	}
 
 	// msg
-	msg := ""
-	paramLen := len(info.Args)
-	if paramLen > 0 {
-		msg = fmt.Sprint(info.Args...)
-	}
+	buf = append(buf, l.buildMsg(info.Format, args, fields)...)
+	buf = append(buf, '\n')
 
-	builder.WriteString(msg)
-	builder.WriteRune('\n')
+	// stack
+	buf = append(buf, info.Stack...)
 
-	return builder.String()
+	return buf
 }
 
-func (l *logger) getLvStr(lv LogLv) string {
-	if lv == LOG_LV_DEBUG {
-		return "DEBUG"
-	} else if lv == LOG_LV_INFO {
-		return "INFO "
-	} else if lv == LOG_LV_WARN {
-		return "WARN "
-	} else if lv == LOG_LV_ERROR {
-		return "ERROR"
-	} else {
-		return ""
-	}
+func (l *logger) buildLayoutLogStr(layout *logLayout, info *LogInfo, args []interface{}, fields []LogField, buf []byte) []byte {
+	msg := l.buildMsg(info.Format, args, fields)
+	buf = layout.format(info, strings.TrimSpace(l.getLvStr(info.Lv)), msg, buf)
+	buf = append(buf, '\n')
+	buf = append(buf, info.Stack...)
+
+	return buf
 }
 
-func (l *logger) printConsoleLogs() {
-	l.popLogs()
-	if len(l.writeLogs) == 0 {
-		<-time.After(time.Millisecond * 10)
-		return
-	}
+// Build the message and the fields into the message buffer.
+// @param format, the printf format, empty mean print the args by fmt.Sprint.
+// @param args, the args.
+// @param fields, the fields.
+// @return []byte, the message, it is valid until next building.
+func (l *logger) buildMsg(format string, args []interface{}, fields []LogField) []byte {
+	w := l.msgBuf
+	w.b = w.b[:0]
+	writeLogMsg(w, format, args, fields)
+	return w.b
+}
 
-	for _, info := range l.writeLogs {
-		logStr := l.buildLogStr(info)
-		if l.printFunc != nil {
-			l.printFunc(info.Lv, logStr)
-		} else {
-			l.linuxPrint(info.Lv, logStr)
-		}
-	}
+func (l *logger) buildJsonLogStr(info *LogInfo, args []interface{}, fields []LogField, buf []byte) []byte {
+	// time
+	buf = append(buf, `{"time":"`...)
+	buf = info.Time.AppendFormat(buf, LOG_JSON_TIME_FORMAT)
+	buf = append(buf, '"')
 
-	l.writeLogs = l.writeLogs[0:0]
-}
+	// level
+	buf = append(buf, `,"level":`...)
+	buf = appendJsonString(buf, strings.TrimSpace(l.getLvStr(info.Lv)))
 
-func (l *logger) linuxPrint(lv LogLv, logStr string) {
-	logPrintStr := ""
-	if lv == LOG_LV_ERROR {
-		logPrintStr = fmt.Sprintf("%c[1;40;31m%s%c[0m", 0x1B, logStr, 0x1B)
-	} else if lv == LOG_LV_WARN {
-		logPrintStr = fmt.Sprintf("%c[1;40;33m%s%c[0m", 0x1B, logStr, 0x1B)
-	} else if lv == LOG_LV_DEBUG {
-		logPrintStr = fmt.Sprintf("%c[1;40;32m%s%c[0m", 0x1B, logStr, 0x1B)
-	} else {
-		logPrintStr = logStr
+	// tag
+	if len(info.Tag) > 0 {
+		buf = append(buf, `,"tag":`...)
+		buf = appendJsonString(buf, info.Tag)
 	}
-	fmt.Print(logPrintStr)
-}
 
-func (l *logger) startDump(file string, dumpFileSize int, dumpThreshold int, dumpIntervalMs uint32) {
-	l.strDumpFile = file
-	l.dumpFileSize = dumpFileSize
-	l.dumpThreshold = dumpThreshold
-	l.dumpIntervalMs = dumpIntervalMs
-	l.queLogs = make([]*LogInfo, 0, LOG_MAX_CACHE_SIZE)
-	l.writeLogs = make([]*LogInfo, 0, LOG_MAX_CACHE_SIZE)
-	l.bDumpOpen = true
-	// go l.dumpLoop()
-}
+	// caller
+	if len(info.Caller) > 0 {
+		buf = append(buf, `,"caller":`...)
+		buf = appendJsonString(buf, info.Caller)
+	}
 
-func (l *logger) stopDump() {
-	l.bDumpOpen = false
-	// l.evtStop.Send()
-	l.evtDumpToFile.Broadcast()
-	// l.evtStopSucc.Wait()
-	// l.strDumpFile = ""
-}
+	// func
+	if len(info.Func) > 0 {
+		buf = append(buf, `,"func":`...)
+		buf = appendJsonString(buf, info.Func)
+	}
 
-func (l *logger) needDump() bool {
-	return len(l.queLogs) >= l.dumpThreshold
-}
+	// msg
+	buf = append(buf, `,"msg":`...)
+	buf = appendJsonBytes(buf, l.buildMsg(info.Format, args, nil))
+
+	// fields
+	if len(fields) > 0 {
+		buf = append(buf, `,"fields":{`...)
+		for i, field := range fields {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
 
-func (l *logger) dump() {
-	l.popLogs()
-	if len(l.writeLogs) == 0 {
-		return
+			buf = appendJsonString(buf, field.Key)
+			buf = append(buf, ':')
+			buf = appendJsonValue(buf, field.Value)
+		}
+
+		buf = append(buf, '}')
 	}
 
-	cnt, err := l.dumpToFile(l.writeLogs)
-	if err != nil {
-		l.dumpToBak(l.writeLogs[cnt:])
+	// stack
+	if len(info.Stack) > 0 {
+		buf = append(buf, `,"stack":`...)
+		buf = appendJsonString(buf, info.Stack)
 	}
 
-	l.writeLogs = l.writeLogs[0:0]
+	buf = append(buf, '}', '\n')
+	return buf
 }
 
-func (l *logger) dumpToFile(logs []*LogInfo) (int, error) {
-	var err error = nil
-	totalCnt := len(logs)
-	idx := 0
-	cnt := int(0)
+// Write the message and the fields of the log.
+// @param w, the buffer.
+// @param format, the printf format, empty mean print the args by fmt.Sprint.
+// @param args, the args.
+// @param fields, the fields.
+func writeLogMsg(w *logBuffer, format string, args []interface{}, fields []LogField) {
+	// msg
+	if len(format) > 0 {
+		fmt.Fprintf(w, format, args...)
+	} else if len(args) > 0 {
+		fmt.Fprint(w, args...)
+	}
 
-	for {
-		// dump one file
-		bNeedRename := false
-		cnt, bNeedRename, err = l.dumpOneFile(logs[idx:])
-		idx += cnt
-		if err != nil {
-			break
+	// fields
+	for _, field := range fields {
+		w.b = append(w.b, ' ')
+		w.b = append(w.b, field.Key...)
+		w.b = append(w.b, '=')
+		if str, ok := field.Value.(string); ok {
+			w.b = append(w.b, str...)
+		} else {
+			fmt.Fprint(w, field.Value)
 		}
+	}
+}
 
-		// rename
-		if bNeedRename {
-			renameErr := l.renameDumpFile()
-			if renameErr != nil {
-				fmt.Println("rename dump file error: ", renameErr)
-			}
+// Split the fields out of the log args, must be called in the writer goroutine.
+// @param args, the log args, which may contain LogField or []LogField.
+// @param fields, the fields carried by the logger.
+// @return []interface{}, the args of the message, it is valid until next splitting.
+// @return []LogField, all the fields, it is valid until next splitting.
+func (l *logger) splitLogArgs(args []interface{}, fields []LogField) ([]interface{}, []LogField) {
+	args, fields, l.splitArgs, l.splitFields = splitLogArgsTo(args, fields, l.splitArgs, l.splitFields)
+	return args, fields
+}
+
+// Split the fields out of the log args into the buffers.
+// @param args, the log args, which may contain LogField or []LogField.
+// @param fields, the fields carried by the logger.
+// @param argBuf, the buffer of the message args.
+// @param fieldBuf, the buffer of the fields.
+// @return []interface{}, the args of the message.
+// @return []LogField, all the fields.
+// @return []interface{}, the buffer of the message args to reuse.
+// @return []LogField, the buffer of the fields to reuse.
+func splitLogArgsTo(args []interface{}, fields []LogField, argBuf []interface{}, fieldBuf []LogField) ([]interface{}, []LogField, []interface{}, []LogField) {
+	bHasField := false
+	for _, arg := range args {
+		switch arg.(type) {
+		case LogField, []LogField:
+			bHasField = true
 		}
 
-		// check end
-		if idx == totalCnt {
+		if bHasField {
 			break
 		}
 	}
 
-	return idx, err
-}
+	if !bHasField {
+		return args, fields, argBuf, fieldBuf
+	}
 
-func (l *logger) dumpOneFile(logs []*LogInfo) (int, bool, error) {
-	var err error = nil
+	msgArgs := argBuf[:0]
+	allFields := append(fieldBuf[:0], fields...)
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case LogField:
+			allFields = append(allFields, v)
+		case []LogField:
+			allFields = append(allFields, v...)
+		default:
+			msgArgs = append(msgArgs, arg)
+		}
+	}
 
-	// open file
-	fileName := l.strDumpFile
-	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
-	if err != nil {
-		fmt.Println("open log dump file error: ", err)
-		return 0, false, err
+	return msgArgs, allFields, msgArgs, allFields
+}
+
+func (l *logger) getLvStr(lv LogLv) string {
+	if lv == LOG_LV_TRACE {
+		return "TRACE"
+	} else if lv == LOG_LV_DEBUG {
+		return "DEBUG"
+	} else if lv == LOG_LV_INFO {
+		return "INFO "
+	} else if lv == LOG_LV_WARN {
+		return "WARN "
+	} else if lv == LOG_LV_ERROR {
+		return "ERROR"
+	} else if lv == LOG_LV_FATAL {
+		return "FATAL"
+	} else {
+		return ""
 	}
+}
 
-	defer f.Close()
+func (l *logger) startDump(file string, dumpFileSize int, dumpThreshold int, dumpIntervalMs uint32, rotateCfg *LogRotateConf) {
+	dumpConf := l.updateDumpConf(func(c *logDumpConf) {
+		c.file = file
+		c.fileSize = dumpFileSize
+		c.rotateCfg = rotateCfg
+	})
 
-	// dump loop
-	totalCnt := len(logs)
-	idx := 0
-	cnt := int(0)
-	bNeedRename := false
+	l.setDumpParams(dumpThreshold, dumpIntervalMs)
+	l.setDumpOpen(true)
+	// go l.dumpLoop()
 
-	for {
-		// batch size
-		batchSize := totalCnt - idx
-		if batchSize > LOG_BATCH_DUMP_COUNT {
-			batchSize = LOG_BATCH_DUMP_COUNT
-		}
+	sink := NewFileLogSink(file, dumpFileSize)
+	if rotateCfg != nil {
+		sink.SetRotateConf(rotateCfg)
+	}
 
-		// dump
-		cnt, err = l.batchDumpToFile(logs[idx:idx+batchSize], f)
-		idx += cnt
-		if err != nil {
-			break
-		}
+	sink.SetBackup(dumpConf.getBakFile(), dumpConf.retrySize)
+	sink.SetErrorCallback(l.onDumpError)
 
-		// check file size
-		size, sizeErr := GetFileSize(fileName)
-		if sizeErr != nil {
-			fmt.Println("GetFileSize error: ", sizeErr)
-		} else if size >= int64(l.dumpFileSize) {
-			bNeedRename = true
-			break
-		}
+	l.removeSink(LOG_SINK_NAME_CONSOLE)
+	l.addSink(LOG_SINK_NAME_DUMP, sink, LOG_LV_TRACE)
+}
 
-		// check end
-		if idx == totalCnt {
-			break
-		}
+func (l *logger) setDumpParams(dumpThreshold int, dumpIntervalMs uint32) {
+	if dumpThreshold <= 0 {
+		dumpThreshold = LOG_DEFAULT_DUMP_THRESHOLD
 	}
 
-	return idx, bNeedRename, err
-}
+	if dumpIntervalMs == 0 {
+		dumpIntervalMs = LOG_DEFAULT_DUMP_INTV
+	}
 
-func (l *logger) dumpToBak(logs []*LogInfo) {
-	f, err := os.OpenFile("dump.log.bak", os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
-	if err != nil {
-		fmt.Println("open dump.log.bak error: ", err)
+	atomic.StoreInt32(&l.dumpThreshold, int32(dumpThreshold))
+	atomic.StoreUint32(&l.dumpIntervalMs, dumpIntervalMs)
+
+	// l.lck.Lock()
+	if l.lck.TryLock(0) != nil {
 		return
 	}
 
-	defer f.Close()
+	defer l.lck.Unlock()
 
-	l.batchDumpToFile(logs, f)
+	// keep the logs in queue
+	if cap(l.queLogs) < LOG_MAX_CACHE_SIZE {
+		l.compactQueue()
+		queLogs := make([]*LogInfo, len(l.queLogs), LOG_MAX_CACHE_SIZE)
+		copy(queLogs, l.queLogs)
+		l.queLogs = queLogs
+	}
 }
 
-func (l *logger) batchDumpToFile(logs []*LogInfo, f *os.File) (int, error) {
-	w := bufio.NewWriter(f)
-	defer w.Flush()
+func (l *logger) stopDump() {
+	l.setDumpOpen(false)
+	l.removeSink(LOG_SINK_NAME_DUMP)
+	l.removeDumpSplits()
+	l.addSink(LOG_SINK_NAME_CONSOLE, l.consoleSink, LOG_LV_TRACE)
+	// l.evtStop.Send()
+	l.evtDumpToFile.Broadcast()
+	// l.evtStopSucc.Wait()
+	// l.strDumpFile = ""
+}
 
-	loopCnt := len(logs)
-	for i := 0; i < loopCnt; i++ {
-		logStr := l.buildLogStr(logs[i])
-		_, err := w.WriteString(logStr)
-		if err != nil {
-			fmt.Println("batchDumpToFile w.WriteString error: ", err)
-			return i, err
-		}
+func (l *logger) setDumpOpen(bDumpOpen bool) {
+	if bDumpOpen {
+		atomic.StoreInt32(&l.bDumpOpen, 1)
+	} else {
+		atomic.StoreInt32(&l.bDumpOpen, 0)
 	}
+}
 
-	return loopCnt, nil
+func (l *logger) isDumpOpen() bool {
+	return atomic.LoadInt32(&l.bDumpOpen) == 1
 }
 
-func (l *logger) renameDumpFile() error {
-	l.dumpFileSno++
-	dir := path.Dir(l.strDumpFile)
-	name := path.Base(l.strDumpFile)
-	ext := path.Ext(name)
-	nameOnly := strings.TrimSuffix(name, ext)
+func (l *logger) checkDebugSwitch() {
+	bExist, _ := IsFileExist(LOG_DEBUG_SWITCH_FILE)
+	if bExist {
+		atomic.StoreInt32(&l.bDebugSwitchOn, 1)
+	} else {
+		atomic.StoreInt32(&l.bDebugSwitchOn, 0)
+	}
+}
 
-	builder := &strings.Builder{}
-	builder.WriteString(nameOnly)
+func (l *logger) needDump() bool {
+	// l.lck.Lock()
+	if l.lck.TryLock(0) != nil {
+		return false
+	}
 
-	now := time.Now()
-	FormatTimeStr("_YYMMDD_hhmmss_", now, builder)
-	FormatUint(l.dumpFileSno, 5, false, builder)
-	builder.WriteString(ext)
-	newName := path.Join(dir, builder.String())
+	defer l.lck.Unlock()
 
-	return os.Rename(l.strDumpFile, newName)
+	return len(l.queLogs)-l.queHead >= int(atomic.LoadInt32(&l.dumpThreshold))
 }
@@ -232,6 +232,8 @@ func ProtectRun(entry func()) {
 			default:
 				log.E("error:", err)
 			}
+
+			FlushAllLoggers()
 		}
 	}()
 